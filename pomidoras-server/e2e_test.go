@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startServer runs the server with cfg on a socket in a temporary directory,
+// keeping its history there too, and returns the socket path once it
+// accepts connections. Calling teardown stops the server and waits for Run
+// to return.
+func startServer(t *testing.T, cfg Config) (socket string, teardown func()) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+	t.Setenv("POMIDORAS_NOTIFY_CMD", "")
+	cfg.SocketPath = filepath.Join(dir, "pomidoras.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("unix", cfg.SocketPath)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		select {
+		case err := <-done:
+			cancel()
+			t.Fatalf("server exited: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return cfg.SocketPath, func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("server: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("server did not shut down")
+		}
+	}
+}
+
+var (
+	binDir    string // Removed by TestMain
+	buildOnce sync.Once
+	ctlPath   string
+	buildErr  error
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	binDir, err = os.MkdirTemp("", "pomidoras-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(binDir)
+	os.Exit(code)
+}
+
+// ctl runs pomidorasctl, built once per test run, against socket and returns
+// its trimmed output.
+func ctl(t *testing.T, socket string, args ...string) (string, error) {
+	t.Helper()
+	buildOnce.Do(func() {
+		dir, err := filepath.Abs(filepath.Join("..", "pomidorasctl"))
+		if err != nil {
+			buildErr = err
+			return
+		}
+		ctlPath = filepath.Join(binDir, "pomidorasctl")
+		out, err := exec.Command("go", "build", "-o", ctlPath, dir).CombinedOutput()
+		if err != nil {
+			buildErr = fmt.Errorf("%v: %s", err, out)
+		}
+	})
+	if buildErr != nil {
+		t.Skipf("cannot build pomidorasctl: %v", buildErr)
+	}
+
+	cmd := exec.Command(ctlPath, append([]string{"--socket", socket}, args...)...)
+	cmd.Env = append(cmd.Environ(), "POMIDORAS_DIAL_RETRIES=0")
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func TestE2EStartAdd(t *testing.T) {
+	socket, teardown := startServer(t, Config{})
+	defer teardown()
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"status"}, "Idle"},
+		{[]string{"start"}, "Timer started."},
+		{[]string{"add", "60"}, "Added 60 seconds."},
+		{[]string{"pause"}, "Paused 1 timers."},
+	}
+	for _, step := range steps {
+		out, err := ctl(t, socket, step.args...)
+		if err != nil || out != step.want {
+			t.Fatalf("%q: got %q (%v), want %q", step.args, out, err, step.want)
+		}
+	}
+
+	out, err := ctl(t, socket, "status", "--seconds")
+	if err != nil {
+		t.Fatalf("status --seconds: %v: %s", err, out)
+	}
+	// 25 minutes and the 60 seconds added, less what passed before pausing.
+	if seconds, _ := strconv.Atoi(out); seconds < 1555 || seconds > 1560 {
+		t.Errorf("got %q seconds remaining, want about 1560", out)
+	}
+}
+
+func TestE2EInfoAndShutdown(t *testing.T) {
+	socket, teardown := startServer(t, Config{Duration: "10m"})
+
+	out, err := ctl(t, socket, "info", "--json")
+	if err != nil {
+		t.Fatalf("info: %v: %s", err, out)
+	}
+	var info struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(out), &info); err != nil || info.Version == "" {
+		t.Errorf("got %q (%v), want info JSON with a version", out, err)
+	}
+
+	teardown()
+	if out, err := ctl(t, socket, "ping"); err == nil {
+		t.Errorf("ping after shutdown: got %q, want an error", out)
+	}
+}
+
+func TestE2EFresh(t *testing.T) {
+	socket, teardown := startServer(t, Config{Duration: "10m"})
+	defer teardown()
+
+	// Without --yes a running session needs confirming, and stdin is empty.
+	out, err := ctl(t, socket, "fresh", "50m")
+	if err != nil || !strings.HasSuffix(out, "Aborted.") {
+		t.Fatalf("fresh: got %q (%v), want it aborted", out, err)
+	}
+
+	if out, err := ctl(t, socket, "fresh", "--quiet", "50m"); err != nil || out != "" {
+		t.Fatalf("fresh --quiet: got %q (%v), want no output", out, err)
+	}
+	out, err = ctl(t, socket, "status", "--seconds")
+	if seconds, _ := strconv.Atoi(out); err != nil || seconds < 2995 || seconds > 3000 {
+		t.Errorf("got %q seconds remaining (%v), want about 3000", out, err)
+	}
+}
+
+func TestE2EElapsed(t *testing.T) {
+	socket, teardown := startServer(t, Config{})
+	defer teardown()
+
+	for _, step := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"status", "--elapsed"}, "00:00"},
+		{[]string{"status", "--elapsed", "--idle-full"}, "25:00"},
+		{[]string{"start"}, "Timer started."},
+		{[]string{"pause"}, "Paused 1 timers."},
+	} {
+		out, err := ctl(t, socket, step.args...)
+		if err != nil || out != step.want {
+			t.Fatalf("%q: got %q (%v), want %q", step.args, out, err, step.want)
+		}
+	}
+	// Only a moment passed between starting and pausing.
+	if out, err := ctl(t, socket, "status", "--elapsed"); err != nil || (out != "Paused 00:00" && out != "Paused 00:01") {
+		t.Errorf("got %q (%v), want about Paused 00:00", out, err)
+	}
+}
+
+func TestE2EWaitPhase(t *testing.T) {
+	socket, teardown := startServer(t, Config{Duration: "25m"})
+	defer teardown()
+
+	if out, err := ctl(t, socket, "wait-phase", "work"); err != nil || out != "" {
+		t.Fatalf("wait-phase work: got %q (%v), want it to return at once", out, err)
+	}
+	out, err := ctl(t, socket, "wait-phase", "--timeout", "500ms", "long_break")
+	if err == nil || out != "Timed out waiting for long_break." {
+		t.Errorf("wait-phase long_break: got %q (%v), want a timeout", out, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ctl(t, socket, "wait-phase", "--timeout", "5s", "break")
+		done <- err
+	}()
+	time.Sleep(200 * time.Millisecond) // Let it subscribe
+	if out, err := ctl(t, socket, "add", "-1500"); err != nil {
+		t.Fatalf("add: %v: %s", err, out)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("wait-phase break: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sakalys/pomidoras/timer"
+)
+
+// activity tracks when the server was last in use, for --idle-shutdown.
+// The server is in use while a connection is open or the timer is not idle.
+type activity struct {
+	mu    sync.Mutex
+	conns int
+	last  time.Time
+}
+
+func newActivity() *activity {
+	return &activity{last: time.Now()}
+}
+
+func (a *activity) connOpened() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.conns++
+	a.last = time.Now()
+}
+
+func (a *activity) connClosed() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.conns--
+	a.last = time.Now()
+}
+
+func (a *activity) touch() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.last = time.Now()
+}
+
+// idleFor reports how long the server has been unused at now, which is zero
+// while any connection is open.
+func (a *activity) idleFor(now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conns > 0 {
+		return 0
+	}
+	return now.Sub(a.last)
+}
+
+// shutdownWhenIdle calls shutdown once the server has been unused for limit,
+// treating a timer that is not idle as use. It checks every interval.
+func shutdownWhenIdle(a *activity, t *timer.Timer, limit, interval time.Duration, shutdown func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if t.Status().State != timer.StateIdle {
+			a.touch()
+			continue
+		}
+		if a.idleFor(now) >= limit {
+			shutdown()
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sakalys/pomidoras/timer"
+)
+
+func TestActivityIdleFor(t *testing.T) {
+	a := newActivity()
+	a.connOpened()
+	if got := a.idleFor(time.Now().Add(time.Hour)); got != 0 {
+		t.Errorf("with a connection open: got %v, want 0", got)
+	}
+	a.connClosed()
+	if got := a.idleFor(time.Now().Add(time.Hour)); got < 59*time.Minute {
+		t.Errorf("after closing: got %v, want about an hour", got)
+	}
+}
+
+func TestShutdownWhenIdle(t *testing.T) {
+	done := make(chan struct{})
+	go shutdownWhenIdle(newActivity(), timer.New(0), 50*time.Millisecond, 10*time.Millisecond, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle server did not shut down")
+	}
+}
+
+func TestShutdownWhenIdleCountdown(t *testing.T) {
+	tm := timer.New(0)
+	tm.AddSeconds(600)
+	defer tm.Pause()
+
+	done := make(chan struct{})
+	go shutdownWhenIdle(newActivity(), tm, 50*time.Millisecond, 10*time.Millisecond, func() { close(done) })
+	select {
+	case <-done:
+		t.Fatal("shut down during a countdown")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
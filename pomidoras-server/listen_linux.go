@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnix listens on the Unix domain socket at path. A positive backlog
+// sets the queue of connections waiting to be accepted, which net.Listen
+// always takes from net.core.somaxconn; the kernel still caps it there.
+func listenUnix(path string, backlog int) (net.Listener, error) {
+	if backlog <= 0 {
+		return net.Listen("unix", path)
+	}
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrUnix{Name: path}); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if err := syscall.Listen(fd, backlog); err != nil {
+		syscall.Close(fd)
+		os.Remove(path)
+		return nil, os.NewSyscallError("listen", err)
+	}
+	file := os.NewFile(uintptr(fd), path)
+	defer file.Close() // FileListener works on a copy
+	listener, err := net.FileListener(file)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	// Like net.Listen, remove the socket file when closed.
+	listener.(*net.UnixListener).SetUnlinkOnClose(true)
+	return listener, nil
+}
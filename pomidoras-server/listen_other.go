@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// listenUnix listens on the Unix domain socket at path. Setting the backlog
+// is only implemented on Linux, see listen_linux.go.
+func listenUnix(path string, backlog int) (net.Listener, error) {
+	if backlog > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: --backlog is only supported on Linux, using the system default")
+	}
+	return net.Listen("unix", path)
+}
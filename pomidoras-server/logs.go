@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultLogBufferSize is how many output lines the server keeps for
+// log requests unless POMIDORAS_LOG_BUFFER says otherwise.
+const defaultLogBufferSize = 200
+
+// captureOutput replaces *f with a pipe whose contents are copied both to
+// the original file and to b, so output from anywhere in the process,
+// including the timer package, ends up in the buffer. The returned restore
+// puts the original file back once everything written so far was copied;
+// call it before exiting so no output is lost.
+func captureOutput(f **os.File, b io.Writer) (restore func(), err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	original := *f
+	*f = w
+
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		if _, err := io.Copy(io.MultiWriter(original, b), r); err != nil {
+			fmt.Fprintf(original, "Error capturing output: %v\n", err)
+		}
+	}()
+	return func() {
+		*f = original
+		w.Close()
+		<-copied
+	}, nil
+}
@@ -1,203 +1,322 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
-	"golang.org/x/term"
+	"github.com/sakalys/pomidoras/server"
+	"github.com/sakalys/pomidoras/timer"
 )
 
-type State string
-
-const (
-	StateCountdown State = "countdown"
-	StateIdle      State = "idle"
-	SocketPath           = "/tmp/pomidoras.sock" // Use a Unix domain socket
-)
-
-type Timer struct {
-	duration        time.Duration
-	initialDuration time.Duration
-	state           State
-	ticker          *time.Ticker
-	mu              sync.RWMutex
-	terminalWidth   int //Added for client
-}
-
-type TimerStatus struct {
-	State    State         `json:"state"`
-	Duration time.Duration `json:"duration"`
-}
-
-// Request types for client-server communication
-type RequestType string
-
-const (
-	RequestTypeStatus     RequestType = "status"
-	RequestTypeAddSeconds RequestType = "add_seconds"
-	RequestTypeReset      RequestType = "reset" // Added reset request
-)
-
-type Request struct {
-	Type    RequestType `json:"type"`
-	Payload string      `json:"payload,omitempty"` // Use string for flexibility
+// SocketPath is the Unix domain socket the server listens on, see
+// server.DefaultSocketPath.
+var SocketPath = server.DefaultSocketPath()
+
+// defaultMinNotifyDuration is the shortest phase that notifies on completion
+// unless POMIDORAS_MIN_NOTIFY_DURATION says otherwise, so tiny test timers
+// stay quiet.
+const defaultMinNotifyDuration = 5 * time.Second
+
+// defaultFlash is how long the status carries the flash hint after a phase
+// completes unless POMIDORAS_FLASH says otherwise, see timer.WithFlash.
+const defaultFlash = 5 * time.Second
+
+// defaultFinalWarningLead is how long before the end of a phase the final
+// warning of POMIDORAS_FINAL_WARNING fires, unless
+// POMIDORAS_FINAL_WARNING_LEAD says otherwise, see timer.WithFinalWarning.
+const defaultFinalWarningLead = 30 * time.Second
+
+// phaseDurationsFromEnv reads the work/break cycle from POMIDORAS_WORK,
+// POMIDORAS_SHORT_BREAK, POMIDORAS_LONG_BREAK,
+// POMIDORAS_LONG_BREAK_INTERVAL and POMIDORAS_BREAK_START_DELAY. Unset or
+// invalid values keep the defaults.
+func phaseDurationsFromEnv() timer.PhaseDurations {
+	phases := timer.DefaultPhaseDurations
+	envDuration("POMIDORAS_WORK", &phases.Work, false)
+	envDuration("POMIDORAS_SHORT_BREAK", &phases.ShortBreak, true)
+	envDuration("POMIDORAS_LONG_BREAK", &phases.LongBreak, true)
+	envDuration("POMIDORAS_BREAK_START_DELAY", &phases.BreakStartDelay, true)
+
+	if value := os.Getenv("POMIDORAS_LONG_BREAK_INTERVAL"); value != "" {
+		interval, err := strconv.Atoi(value)
+		if err != nil || interval < 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_LONG_BREAK_INTERVAL %q, using %d\n", value, phases.LongBreakInterval)
+		} else {
+			phases.LongBreakInterval = interval
+		}
+	}
+	return phases
 }
 
-type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Status  TimerStatus `json:"status,omitempty"`
+// notifySendConfigFromEnv reads the notification urgency from
+// POMIDORAS_URGENCY, overridden per phase by POMIDORAS_WORK_URGENCY and
+// POMIDORAS_BREAK_URGENCY, and the expiry from POMIDORAS_NOTIFY_TIMEOUT.
+func notifySendConfigFromEnv() timer.NotifySendConfig {
+	config := timer.DefaultNotifySendConfig
+	envUrgency("POMIDORAS_URGENCY", &config.WorkUrgency)
+	envUrgency("POMIDORAS_URGENCY", &config.BreakUrgency)
+	envUrgency("POMIDORAS_WORK_URGENCY", &config.WorkUrgency)
+	envUrgency("POMIDORAS_BREAK_URGENCY", &config.BreakUrgency)
+	envDuration("POMIDORAS_NOTIFY_TIMEOUT", &config.Timeout, true)
+	return config
 }
 
-func NewTimer(initialDuration time.Duration) *Timer {
-	state := StateIdle
-	if initialDuration > 0 {
-		state = StateCountdown
+// notifierFromEnv returns the desktop notifier, running the command in
+// POMIDORAS_NOTIFY_CMD (default notify-send) configured by
+// notifySendConfigFromEnv. It returns nil, disabling notifications, when the
+// variable is set but empty or the command cannot be found, saying so once
+// here rather than failing on every notification.
+func notifierFromEnv() timer.Notifier {
+	config := notifySendConfigFromEnv()
+	if value, ok := os.LookupEnv("POMIDORAS_NOTIFY_CMD"); ok {
+		config.Command = strings.TrimSpace(value)
+		if config.Command == "" {
+			fmt.Println("POMIDORAS_NOTIFY_CMD is empty, notifications disabled.")
+			return nil
+		}
 	}
-
-	width, _, err := term.GetSize(int(os.Stdout.Fd())) // Get terminal size, added for client
-	if err != nil {
-		width = 80 // Default width if we can't get the size
+	if _, err := exec.LookPath(config.Command); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: notification command %q not found, notifications disabled\n", config.Command)
+		return nil
 	}
+	return timer.NotifySend(config)
+}
 
-	return &Timer{
-		duration:        initialDuration,
-		initialDuration: initialDuration,
-		state:           state,
-		terminalWidth:   width, //Added for client
+// messagesFromEnv reads the notification bodies from POMIDORAS_WORK_MESSAGE
+// and POMIDORAS_BREAK_MESSAGE, which may use {phase} and {next_phase}.
+func messagesFromEnv() timer.Messages {
+	messages := timer.DefaultMessages
+	if value := os.Getenv("POMIDORAS_WORK_MESSAGE"); value != "" {
+		messages.Work = value
+	}
+	if value := os.Getenv("POMIDORAS_BREAK_MESSAGE"); value != "" {
+		messages.Break = value
 	}
+	return messages
 }
 
-func (t *Timer) Start() {
-	if t.duration > 0 {
-		t.mu.Lock()
-		t.state = StateCountdown
-		t.ticker = time.NewTicker(1 * time.Second)
-		t.mu.Unlock()
-		go t.run()
-	} else {
-		t.mu.Lock()
-		t.state = StateIdle
-		t.mu.Unlock()
+// soundsFromEnv reads the completion sounds from POMIDORAS_WORK_SOUND and
+// POMIDORAS_BREAK_SOUND, played with POMIDORAS_SOUND_PLAYER.
+func soundsFromEnv() timer.Sounds {
+	return timer.Sounds{
+		Work:   os.Getenv("POMIDORAS_WORK_SOUND"),
+		Break:  os.Getenv("POMIDORAS_BREAK_SOUND"),
+		Player: os.Getenv("POMIDORAS_SOUND_PLAYER"),
 	}
 }
 
-func (t *Timer) run() {
-	for range t.ticker.C {
-		t.mu.Lock()
-		t.duration -= time.Second
-		if t.duration <= 0 {
-			t.state = StateIdle
-			t.ticker.Stop()
-			t.duration = 0
-			t.sendNotification("Pomidoras", "Time's up!") // Send notification
-			t.mu.Unlock()
-			return
-		}
-		t.mu.Unlock()
+// dndFromEnv reads the do-not-disturb commands from POMIDORAS_DND_ON and
+// POMIDORAS_DND_OFF, run as work sessions start and end.
+func dndFromEnv() timer.DND {
+	return timer.DND{
+		On:  os.Getenv("POMIDORAS_DND_ON"),
+		Off: os.Getenv("POMIDORAS_DND_OFF"),
 	}
 }
 
-func (t *Timer) AddSeconds(seconds int) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	t.duration += time.Duration(seconds) * time.Second
-	if t.state == StateIdle && t.duration > 0 {
-		t.state = StateCountdown
-		t.ticker = time.NewTicker(1 * time.Second)
-		go t.run()
+// quietHoursFromEnv reads the quiet hours from POMIDORAS_QUIET_HOURS, such
+// as 09:00-10:00,22:00-07:00.
+func quietHoursFromEnv() []timer.QuietWindow {
+	value := os.Getenv("POMIDORAS_QUIET_HOURS")
+	if value == "" {
+		return nil
 	}
+	windows, err := timer.ParseQuietHours(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_QUIET_HOURS %q: %v, not using quiet hours\n", value, err)
+		return nil
+	}
+	return windows
 }
 
-func (t *Timer) Reset() {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	t.duration = t.initialDuration
-	if t.ticker != nil {
-		t.ticker.Stop()
+// remindersFromEnv reads the reminder times from POMIDORAS_REMINDERS, a
+// comma separated list of durations before the end of a work session.
+func remindersFromEnv() []time.Duration {
+	value := os.Getenv("POMIDORAS_REMINDERS")
+	if value == "" {
+		return nil
 	}
-	if t.duration > 0 {
-		t.state = StateCountdown
-		t.ticker = time.NewTicker(1 * time.Second)
-		go t.run()
-	} else {
-		t.state = StateIdle
+	var reminders []time.Duration
+	for _, field := range strings.Split(value, ",") {
+		before, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil || before <= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_REMINDERS %q, not sending reminders\n", value)
+			return nil
+		}
+		reminders = append(reminders, before)
 	}
+	return reminders
 }
 
-func (t *Timer) GetStatus() TimerStatus {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	return TimerStatus{State: t.state, Duration: t.duration}
+// notificationsFromEnv reads which events notify from
+// POMIDORAS_NOTIFY_REMINDERS and POMIDORAS_NOTIFY_COMPLETION.
+func notificationsFromEnv() timer.Notifications {
+	notifications := timer.DefaultNotifications
+	envBool("POMIDORAS_NOTIFY_REMINDERS", &notifications.Reminders)
+	envBool("POMIDORAS_NOTIFY_COMPLETION", &notifications.Completion)
+	envBool("POMIDORAS_NOTIFY_GOAL", &notifications.Goal)
+	return notifications
 }
 
-// sendNotification sends a desktop notification using notify-send.
-func (t *Timer) sendNotification(title, message string) {
-	cmd := exec.Command("notify-send", "-u", "critical", title, message)
-	err := cmd.Run()
+// envBool overwrites dst with the boolean in the named environment variable,
+// warning and leaving dst untouched if it does not parse.
+func envBool(name string, dst *bool) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	b, err := strconv.ParseBool(value)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error sending notification: %v\n", err)
-		// Consider logging the error to a file
+		fmt.Fprintf(os.Stderr, "Warning: invalid %s %q, using %t\n", name, value, *dst)
+		return
 	}
+	*dst = b
 }
 
-// ----  Server-Specific Code ----
+// envUrgency overwrites dst with the notify-send urgency in the named
+// environment variable, warning and leaving dst untouched if it is unknown.
+func envUrgency(name string, dst *string) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	urgency, err := timer.ParseUrgency(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid %s: %v, using %s\n", name, err, *dst)
+		return
+	}
+	*dst = urgency
+}
 
-func handleConnection(conn net.Conn, timer *Timer) {
-	defer conn.Close()
+// envDuration overwrites dst with the duration in the named environment
+// variable, warning and leaving dst untouched if it does not parse.
+func envDuration(name string, dst *time.Duration, allowZero bool) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 || (duration == 0 && !allowZero) {
+		fmt.Fprintf(os.Stderr, "Warning: invalid %s %q, using %v\n", name, value, *dst)
+		return
+	}
+	*dst = duration
+}
 
-	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+// Config is what Run needs beyond the POMIDORAS_* environment, which Run
+// reads itself. main fills it in from the command line.
+type Config struct {
+	SocketPath   string
+	Duration     string // The optional duration argument, like 25m
+	IdleShutdown time.Duration
+	ResumeLast   bool
+	TCPAddr      string
+	TLSCert      string
+	TLSKey       string
+	Token        string            // Required with TCPAddr, see listenTCP
+	TickLog      string            // File to append minute ticks to, see timer.WithTickLog
+	Backlog      int               // Unix socket listen backlog; 0 is the system default
+	MaxConns     int               // Connections served at once, see server.WithMaxConnections
+	Logs         *server.LogBuffer // Served with RequestTypeLogs; may be nil
+}
 
-	var req Request
-	if err := decoder.Decode(&req); err != nil {
-		response := Response{Success: false, Message: "Invalid request format."}
-		encoder.Encode(response) // Send error response
-		return
+func main() {
+	cfg := Config{SocketPath: SocketPath}
+	flag.DurationVar(&cfg.IdleShutdown, "idle-shutdown", 0, "exit after this long without connections while the timer is idle (0 never exits)")
+	flag.BoolVar(&cfg.ResumeLast, "resume-last", false, "without a duration argument, start with the length of the last recorded work session")
+	flag.StringVar(&cfg.TCPAddr, "tcp", "", "also listen for remote clients on this TCP address, like :7070; requires POMIDORAS_TOKEN")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", "", "serve --tcp over TLS with this certificate file")
+	flag.StringVar(&cfg.TLSKey, "tls-key", "", "private key file for --tls-cert")
+	flag.IntVar(&cfg.Backlog, "backlog", 0, "queue up to this many connections waiting to be accepted on the Unix socket (0 is the system default)")
+	flag.IntVar(&cfg.MaxConns, "max-conns", 0, "serve at most this many connections at once, answering others busy (0 is unlimited)")
+	flag.StringVar(&cfg.TickLog, "tick-log", "", "append a timestamped line to this file whenever the countdown's displayed minute changes")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "Usage: pomidoras-server [--idle-shutdown <duration>] [--resume-last] [--tcp <address> [--tls-cert <file> --tls-key <file>]] [--backlog <n>] [--max-conns <n>] [--tick-log <file>] [duration]")
+		flag.PrintDefaults()
+		fmt.Fprintln(out, "\nThe Unix socket is always served and needs no token. --tcp makes the timer")
+		fmt.Fprintln(out, "controllable by anyone who can reach the address and knows POMIDORAS_TOKEN;")
+		fmt.Fprintln(out, "without TLS the token travels in the clear, so use it only on trusted networks.")
+		fmt.Fprintln(out, "\nStarted by systemd socket activation, the server listens on the passed socket")
+		fmt.Fprintln(out, "instead of creating its own; --backlog then has no effect.")
+	}
+	flag.Parse()
+	cfg.Duration = flag.Arg(0)
+
+	// Remote clients must authenticate, see listenTCP.
+	cfg.Token = os.Getenv("POMIDORAS_TOKEN")
+	if cfg.TCPAddr != "" && cfg.Token == "" {
+		fmt.Fprintln(os.Stderr, "--tcp requires POMIDORAS_TOKEN to be set.")
+		os.Exit(2)
+	}
+	if cfg.TCPAddr == "" && (cfg.TLSCert != "" || cfg.TLSKey != "") {
+		fmt.Fprintln(os.Stderr, "--tls-cert and --tls-key need --tcp.")
+		os.Exit(2)
 	}
 
-	var response Response
-	switch req.Type {
-	case RequestTypeStatus:
-		status := timer.GetStatus()
-		response = Response{Success: true, Status: status}
-	case RequestTypeAddSeconds:
-		seconds, err := strconv.Atoi(req.Payload)
-		if err != nil {
-			response = Response{Success: false, Message: "Invalid seconds value."}
+	// Keep recent output for RequestTypeLogs, starting early so that
+	// configuration warnings are included.
+	logSize := defaultLogBufferSize
+	if value := os.Getenv("POMIDORAS_LOG_BUFFER"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_LOG_BUFFER %q, using %d\n", value, logSize)
 		} else {
-			timer.AddSeconds(seconds)
-			response = Response{Success: true, Message: fmt.Sprintf("Added %d seconds.", seconds)}
+			logSize = size
 		}
-	case RequestTypeReset: // Handle the reset request
-		timer.Reset()
-		response = Response{Success: true, Message: "Timer reset."}
-
-	default:
-		response = Response{Success: false, Message: "Unknown request type."}
+	}
+	cfg.Logs = server.NewLogBuffer(logSize)
+	var restoreOutput []func()
+	for _, f := range []**os.File{&os.Stdout, &os.Stderr} {
+		restore, err := captureOutput(f, cfg.Logs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error capturing output: %v\n", err)
+			continue
+		}
+		restoreOutput = append(restoreOutput, restore)
 	}
 
-	if err := encoder.Encode(response); err != nil {
-		fmt.Fprintf(os.Stderr, "Error encoding response: %v\n", err)
+	// Graceful shutdown on interrupt signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := Run(ctx, cfg)
+	stop()
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	// Flush captured output before exiting, which would drop anything
+	// still in the pipes.
+	for _, restore := range restoreOutput {
+		restore()
+	}
+	if err != nil {
+		os.Exit(1)
 	}
 }
 
-func main() {
+// Run serves the timer on cfg.SocketPath, and cfg.TCPAddr if set, until ctx
+// is done or the idle shutdown fires. It returns an error only if the
+// server could not start.
+func Run(ctx context.Context, cfg Config) error {
+	if cfg.TCPAddr != "" && cfg.Token == "" {
+		return errors.New("--tcp requires a token")
+	}
+
 	// Get initial duration from command-line arguments (optional)
 	initialDuration := 0 * time.Second
-	if len(os.Args) > 1 {
-		durationStr := os.Args[1]
-		duration, err := time.ParseDuration(durationStr) // Parse as a duration string
+	if cfg.Duration != "" {
+		duration, err := time.ParseDuration(cfg.Duration) // Parse as a duration string
 		if err != nil {
 			fmt.Println("duration:", err)
 			fmt.Println("Invalid duration format. Using 0s.")
@@ -205,31 +324,202 @@ func main() {
 			initialDuration = duration
 		}
 	}
-	timer := NewTimer(initialDuration)
-	timer.Start()
+	history := timer.NewDailyHistory(timer.DefaultHistoryDir())
+	if value := os.Getenv("POMIDORAS_HISTORY_RETENTION"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_HISTORY_RETENTION %q, keeping all history\n", value)
+		} else if removed, err := history.Prune(days, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error pruning history: %v\n", err)
+		} else if removed > 0 {
+			fmt.Printf("Pruned %d history files older than %d days.\n", removed, days)
+		}
+	}
+	if cfg.ResumeLast && cfg.Duration == "" {
+		last, ok, err := history.LastSession()
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		case !ok:
+			fmt.Println("No recorded session to resume.")
+		default:
+			initialDuration = last.Duration
+			fmt.Printf("Resuming the last session length, %v.\n", initialDuration)
+		}
+	}
+	nudgeAmount := time.Minute
+	envDuration("POMIDORAS_NUDGE", &nudgeAmount, false)
+	heartbeat := server.DefaultHeartbeat
+	envDuration("POMIDORAS_HEARTBEAT", &heartbeat, true)
+	serverOpts := []server.Option{server.WithNudge(nudgeAmount), server.WithHeartbeat(heartbeat)}
+	if cfg.Logs != nil {
+		serverOpts = append(serverOpts, server.WithLogs(cfg.Logs))
+	}
+	if cfg.MaxConns > 0 {
+		serverOpts = append(serverOpts, server.WithMaxConnections(cfg.MaxConns))
+	}
+	if value := os.Getenv("POMIDORAS_LOCK_DURING_WORK"); value != "" {
+		lock, err := strconv.ParseBool(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_LOCK_DURING_WORK %q, not locking\n", value)
+		}
+		serverOpts = append(serverOpts, server.WithFocusLock(lock))
+	}
+	if value := os.Getenv("POMIDORAS_ADD_LIMIT"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_ADD_LIMIT %q, not rate limiting\n", value)
+		} else {
+			serverOpts = append(serverOpts, server.WithAddLimit(limit))
+		}
+	}
+
+	resetPreservesCount := true
+	envBool("POMIDORAS_RESET_PRESERVES_COUNT", &resetPreservesCount)
+	var minRemaining time.Duration
+	envDuration("POMIDORAS_MIN_REMAINING", &minRemaining, true)
+	var dailyGoal int
+	if value := os.Getenv("POMIDORAS_DAILY_GOAL"); value != "" {
+		goal, err := strconv.Atoi(value)
+		if err != nil || goal < 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_DAILY_GOAL %q, setting no goal\n", value)
+		} else {
+			dailyGoal = goal
+		}
+	}
+	// Next to the socket by default, so it is just as private.
+	activeFile := filepath.Join(filepath.Dir(cfg.SocketPath), "pomidoras.active")
+	if value, ok := os.LookupEnv("POMIDORAS_ACTIVE_FILE"); ok {
+		activeFile = value // Empty turns it off
+	}
+	flash := defaultFlash
+	envDuration("POMIDORAS_FLASH", &flash, true)
+	var finalWarning time.Duration
+	finalWarningOn := false
+	envBool("POMIDORAS_FINAL_WARNING", &finalWarningOn)
+	if finalWarningOn {
+		finalWarning = defaultFinalWarningLead
+		envDuration("POMIDORAS_FINAL_WARNING_LEAD", &finalWarning, false)
+	}
+	minNotify := defaultMinNotifyDuration
+	envDuration("POMIDORAS_MIN_NOTIFY_DURATION", &minNotify, true)
+
+	timerOpts := []timer.Option{
+		timer.WithPhases(phaseDurationsFromEnv()),
+		timer.WithHistory(history),
+		timer.WithNotifier(notifierFromEnv()),
+		timer.WithMessages(messagesFromEnv()),
+		timer.WithNotifications(notificationsFromEnv()),
+		timer.WithReminders(remindersFromEnv()...),
+		timer.WithReminderCommand(os.Getenv("POMIDORAS_ON_REMINDER")),
+		timer.WithFinalWarning(finalWarning),
+		timer.WithCompletionFIFO(os.Getenv("POMIDORAS_COMPLETE_FIFO")),
+		timer.WithSounds(soundsFromEnv()),
+		timer.WithDND(dndFromEnv()),
+		timer.WithQuietHours(quietHoursFromEnv()...),
+		timer.WithResetPreservesCount(resetPreservesCount),
+		timer.WithMinRemaining(minRemaining),
+		timer.WithMinNotifyDuration(minNotify),
+		timer.WithDailyGoal(dailyGoal),
+		timer.WithFlash(flash),
+		timer.WithActiveFile(activeFile),
+	}
+	if cfg.TickLog != "" {
+		tickLog, err := os.OpenFile(cfg.TickLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening tick log: %w", err)
+		}
+		// Not closed: the timer keeps ticking until the process exits.
+		timerOpts = append(timerOpts, timer.WithTickLog(tickLog))
+	}
+	t := timer.New(initialDuration, timerOpts...)
+	defer func() {
+		t.Stop()
+		if activeFile != "" {
+			os.Remove(activeFile) // Nothing counts down once the server is gone
+		}
+	}()
 
-	// Remove any existing socket file
-	os.Remove(SocketPath)
+	var tcpListener net.Listener
+	if cfg.TCPAddr != "" {
+		serverOpts = append(serverOpts, server.WithToken(cfg.Token))
+		listener, err := listenTCP(cfg.TCPAddr, cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return fmt.Errorf("listening on %s: %w", cfg.TCPAddr, err)
+		}
+		tcpListener = listener
+	}
+	srv := server.New(t, serverOpts...)
 
-	listener, err := net.Listen("unix", SocketPath)
+	// Under socket activation systemd owns the socket, and it stays in
+	// place for the next activation when the server exits.
+	listener, err := sdListener()
 	if err != nil {
-		fmt.Println("Error listening:", err)
-		os.Exit(1)
+		if tcpListener != nil {
+			tcpListener.Close()
+		}
+		return err
+	}
+	if listener != nil {
+		fmt.Println("Server listening on", listener.Addr(), "passed by systemd")
+	} else {
+		// Remove any existing socket file
+		os.Remove(cfg.SocketPath)
+
+		listener, err = listenUnix(cfg.SocketPath, cfg.Backlog)
+		if err != nil {
+			if tcpListener != nil {
+				tcpListener.Close()
+			}
+			return fmt.Errorf("listening on %s: %w", cfg.SocketPath, err)
+		}
+		fmt.Println("Server listening on", cfg.SocketPath)
+	}
+	if tcpListener != nil {
+		fmt.Println("Server listening on", tcpListener.Addr())
+	}
+	t.Start() // Only once clients can reach it
+	if err := sdNotify("READY=1"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error notifying systemd: %v\n", err)
 	}
-	defer listener.Close()
 
-	fmt.Println("Server listening on", SocketPath)
+	ctx, shutdown := context.WithCancel(ctx)
+	defer shutdown()
+	active := newActivity()
+	if cfg.IdleShutdown > 0 {
+		go shutdownWhenIdle(active, t, cfg.IdleShutdown, min(cfg.IdleShutdown, time.Second), shutdown)
+	}
 
-	// Graceful shutdown on interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	var wg sync.WaitGroup
+	if tcpListener != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			acceptLoop(tcpListener, active, srv.HandleRemoteConnection)
+		}()
+	}
+	wg.Add(1)
 	go func() {
-		<-sigChan
-		fmt.Println("Shutting down server...")
-		listener.Close() // Close the listener to stop accepting new connections
-		os.Exit(0)
+		defer wg.Done()
+		acceptLoop(listener, active, srv.HandleConnection)
 	}()
 
+	<-ctx.Done()
+	fmt.Println("Shutting down server...")
+	if err := sdNotify("STOPPING=1"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error notifying systemd: %v\n", err)
+	}
+	listener.Close() // Close the listeners to stop accepting new connections
+	if tcpListener != nil {
+		tcpListener.Close()
+	}
+	wg.Wait()
+	return nil
+}
+
+// acceptLoop serves every connection accepted from listener with handle, each
+// on its own goroutine, until the listener is closed.
+func acceptLoop(listener net.Listener, active *activity, handle func(net.Conn)) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -240,7 +530,10 @@ func main() {
 			fmt.Println("Error accepting connection:", err)
 			continue
 		}
-		go handleConnection(conn, timer)
+		active.connOpened()
+		go func() {
+			defer active.connClosed()
+			handle(conn)
+		}()
 	}
 }
-
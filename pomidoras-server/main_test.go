@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestNotifierFromEnv(t *testing.T) {
+	tests := []struct {
+		command string
+		enabled bool
+	}{
+		{"", false},
+		{"  ", false},
+		{"pomidoras-no-such-command", false},
+		{"true", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("POMIDORAS_NOTIFY_CMD", tt.command)
+		if enabled := notifierFromEnv() != nil; enabled != tt.enabled {
+			t.Errorf("POMIDORAS_NOTIFY_CMD=%q: got enabled %t, want %t", tt.command, enabled, tt.enabled)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenerFromFDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activated.sock")
+	passed, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer passed.Close()
+	file, err := passed.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// listenerFromFDs closes the descriptor, like the one systemd passes.
+	dup, err := syscall.Dup(int(file.Fd()))
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := uintptr(dup)
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	if listener, err := listenerFromFDs(fd); listener != nil || err != nil {
+		t.Fatalf("got %v, %v for another process's sockets, want nil", listener, err)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS still set")
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	listener, err := listenerFromFDs(fd)
+	if err != nil || listener == nil {
+		t.Fatalf("got %v, %v, want the passed socket", listener, err)
+	}
+	defer listener.Close()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if _, err := listener.Accept(); err != nil {
+		t.Errorf("accepting on the passed socket: %v", err)
+	}
+}
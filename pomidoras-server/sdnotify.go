@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// sdListenFDsStart is the first file descriptor systemd passes by socket
+// activation, SD_LISTEN_FDS_START in sd_listen_fds(3).
+const sdListenFDsStart = 3
+
+// sdNotify sends state, such as "READY=1", to the service manager socket in
+// $NOTIFY_SOCKET, see sd_notify(3). It does nothing when NOTIFY_SOCKET is
+// unset, as it is outside a Type=notify systemd unit.
+func sdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	if path[0] == '@' {
+		path = "\x00" + path[1:] // Abstract namespace socket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdListener returns the listening socket systemd passed by socket
+// activation, see sd_listen_fds(3), or nil when the server was not
+// socket-activated: LISTEN_FDS is unset or LISTEN_PID names another process.
+func sdListener() (net.Listener, error) {
+	return listenerFromFDs(sdListenFDsStart)
+}
+
+// listenerFromFDs is sdListener with the passed file descriptors starting
+// at first. Only the first one is used. The variables are unset either way,
+// so that commands run by the server do not take the socket for theirs.
+func listenerFromFDs(first uintptr) (net.Listener, error) {
+	pid, pidErr := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	fds, fdsErr := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pidErr != nil || fdsErr != nil || pid != os.Getpid() || fds < 1 {
+		return nil, nil
+	}
+	if fds > 1 {
+		fmt.Fprintf(os.Stderr, "Warning: systemd passed %d sockets, listening on the first only\n", fds)
+	}
+
+	file := os.NewFile(first, "LISTEN_FDS")
+	defer file.Close() // FileListener works on a copy
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("socket passed by systemd: %w", err)
+	}
+	return listener, nil
+}
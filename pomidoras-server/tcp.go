@@ -0,0 +1,32 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+)
+
+// listenTCP listens for remote clients on addr, such as ":7070", wrapping
+// connections in TLS when certFile and keyFile are given.
+//
+// Unlike the Unix socket, which only its owner can open, a TCP listener is
+// reachable by anyone on the network, so its connections are served with
+// server.HandleRemoteConnection and every request must carry the shared
+// token. Without TLS the token and every request cross the network in the
+// clear; plain TCP is only meant for trusted networks or behind a tunnel.
+func listenTCP(addr, certFile, keyFile string) (net.Listener, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("--tls-cert and --tls-key must be given together")
+	}
+	if certFile == "" {
+		return net.Listen("tcp", addr)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sakalys/pomidoras/server"
+	"github.com/sakalys/pomidoras/timer"
+	"golang.org/x/term"
+)
+
+// signalHandler adjusts the running timer from outside the terminal:
+// SIGUSR1 adds a minute and SIGUSR2 takes one away. Interrupts are left to
+// main, which cancels Run. The zero value is ready to use.
+type signalHandler struct {
+	setup sync.Once
+	stop  sync.Once
+	ch    chan os.Signal // nil until Setup runs
+}
+
+// Setup installs the handlers for t. Only the first call has any effect.
+func (h *signalHandler) Setup(t *timer.Timer) {
+	h.setup.Do(func() {
+		h.ch = make(chan os.Signal, 1)
+		signal.Notify(h.ch, syscall.SIGUSR1, syscall.SIGUSR2)
+		go func() {
+			for sig := range h.ch {
+				switch sig {
+				case syscall.SIGUSR1:
+					t.AddMinutes(1)
+				case syscall.SIGUSR2:
+					t.AddMinutes(-1)
+				}
+			}
+		}()
+	})
+}
+
+// Stop restores the default signal behavior and ends the handler goroutine.
+func (h *signalHandler) Stop() {
+	h.stop.Do(func() {
+		if h.ch != nil {
+			signal.Stop(h.ch)
+			close(h.ch)
+		}
+	})
+}
+
+// serve answers pomidorasctl requests about t on the Unix domain socket at
+// path until the returned listener is closed, replacing any stale socket
+// file.
+func serve(t *timer.Timer, path string) (net.Listener, error) {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := server.New(t)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					fmt.Fprintln(os.Stderr, "Error accepting connection:", err)
+				}
+				return
+			}
+			go srv.HandleConnection(conn)
+		}
+	}()
+	return listener, nil
+}
+
+// desktopNotifier returns a notify-send notifier, or nil with a warning if
+// notify-send is not installed; the countdown on the terminal is enough.
+func desktopNotifier() timer.Notifier {
+	config := timer.DefaultNotifySendConfig
+	if _, err := exec.LookPath(config.Command); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s not found, desktop notifications disabled\n", config.Command)
+		return nil
+	}
+	return timer.NotifySend(config)
+}
+
+// hideCursor hides the blinking cursor while the countdown is redrawn in
+// place on out, if out is a terminal, and returns the function that shows it
+// again. Run defers that, so the cursor comes back however Run returns,
+// including after an interrupt cancels it or a panic.
+func hideCursor(out *os.File) (restore func()) {
+	if !term.IsTerminal(int(out.Fd())) {
+		return func() {}
+	}
+	fmt.Fprint(out, "\x1b[?25l")
+	return func() { fmt.Fprint(out, "\x1b[?25h") }
+}
+
+// Config is what Run needs, filled in by main from the command line.
+type Config struct {
+	Duration   time.Duration
+	Bell       bool     // Ring the terminal bell when the countdown ends
+	Notify     bool     // Send a desktop notification when the countdown ends
+	SocketPath string   // Also serve pomidorasctl requests here; empty does not
+	Output     *os.File // Where the countdown is drawn, in place on a terminal
+}
+
+// Run counts down a single work session of cfg.Duration on cfg.Output,
+// returning once it ends, a request on cfg.SocketPath leaves the timer idle
+// without completing it, such as "reset 0", or ctx is done. It only fails if
+// it cannot listen on cfg.SocketPath.
+func Run(ctx context.Context, cfg Config) error {
+	// A single work session without breaks, drawn in place on the terminal.
+	completed := make(chan struct{}, 1)
+	opts := []timer.Option{
+		timer.WithPhases(timer.PhaseDurations{Work: cfg.Duration}),
+		timer.WithOutput(cfg.Output),
+		// Notifiers run after the timer goes idle, so wait for OnComplete,
+		// which is called once they are done, rather than for the idle state.
+		timer.OnComplete(func(timer.Phase) {
+			select {
+			case completed <- struct{}{}:
+			default:
+			}
+		}),
+	}
+	var notifiers []timer.Notifier
+	if cfg.Bell {
+		notifiers = append(notifiers, timer.Bell(cfg.Output))
+	}
+	if cfg.Notify {
+		notifiers = append(notifiers, desktopNotifier())
+	}
+	if len(notifiers) > 0 {
+		opts = append(opts, timer.WithNotifier(timer.Notifiers(notifiers...)))
+	}
+	t := timer.New(cfg.Duration, opts...)
+	events := t.Events()
+	defer t.Unsubscribe(events)
+	var signals signalHandler
+	signals.Setup(t)
+	defer signals.Stop()
+	if cfg.SocketPath != "" {
+		listener, err := serve(t, cfg.SocketPath)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+	}
+	defer hideCursor(cfg.Output)()
+	t.Start()
+
+	changed := t.Changed()
+	completing := false // The timer went idle by completing; OnComplete follows
+	for {
+		select {
+		case <-ctx.Done():
+			t.Pause()
+			fmt.Fprintln(cfg.Output) // Off the countdown line
+			return nil
+		case <-completed:
+			fmt.Fprintln(cfg.Output, "Time's up!")
+			return nil
+		case <-changed:
+		}
+		changed = t.Changed()
+		// A completion is published before the change it causes, so it is
+		// already waiting here. Draining on every change also keeps the
+		// ticks from filling the buffer.
+		for len(events) > 0 {
+			if event := <-events; event.Type == timer.EventCompleted {
+				completing = true
+			}
+		}
+		if !completing && t.Status().State == timer.StateIdle {
+			fmt.Fprintln(cfg.Output) // Off the countdown line
+			return nil
+		}
+	}
+}
+
+func main() {
+	bell := flag.Bool("bell", false, "ring the terminal bell when the countdown ends")
+	listen := flag.Bool("listen", false, "also accept pomidorasctl requests on the control socket")
+	noNotify := flag.Bool("no-notify", false, "do not send a desktop notification when the countdown ends")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: pomidoras [--bell] [--no-notify] [--listen] <duration>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	duration, err := time.ParseDuration(flag.Arg(0))
+	if err != nil || duration <= 0 {
+		fmt.Println("Invalid duration:", flag.Arg(0))
+		os.Exit(1)
+	}
+
+	cfg := Config{Duration: duration, Bell: *bell, Notify: !*noNotify, Output: os.Stdout}
+	if *listen {
+		cfg.SocketPath = server.DefaultSocketPath()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := Run(ctx, cfg); err != nil {
+		fmt.Println("Error listening:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sakalys/pomidoras/server"
+	"github.com/sakalys/pomidoras/timer"
+)
+
+func TestSignalHandlerIdempotent(t *testing.T) {
+	var h signalHandler
+	tm := timer.New(0)
+	// The first call may also start the os/signal loop, which never exits.
+	h.Setup(tm)
+	running := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		h.Setup(tm)
+	}
+	if after := runtime.NumGoroutine(); after != running {
+		t.Errorf("%d goroutines after repeated setup, want %d", after, running)
+	}
+
+	h.Stop()
+	h.Stop() // Must not close the channel twice
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() >= running && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after != running-1 {
+		t.Errorf("%d goroutines after stopping, want %d", after, running-1)
+	}
+}
+
+func TestRun(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	if err := Run(context.Background(), Config{Duration: time.Second, Output: out}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(out.Name())
+	if !strings.HasSuffix(string(got), "Time's up!\n") {
+		t.Errorf("got %q, want it to end with Time's up!", got)
+	}
+	if strings.Contains(string(got), "\x1b[?25") {
+		t.Errorf("got %q, want the cursor left alone outside a terminal", got)
+	}
+
+	// Cancelling stops the countdown early without an error.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := Run(ctx, Config{Duration: time.Hour, Output: out}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run returned %v after cancelling", elapsed)
+	}
+}
+
+func TestRunResetIdle(t *testing.T) {
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	socket := filepath.Join(dir, "pomidoras.sock")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(context.Background(), Config{Duration: time.Hour, SocketPath: socket, Output: out})
+	}()
+
+	var conn net.Conn
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if conn, err = net.Dial("unix", socket); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Run did not listen:", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(server.Request{Type: server.RequestTypeReset, Payload: "0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return once reset to idle")
+	}
+	if got, _ := os.ReadFile(out.Name()); strings.Contains(string(got), "Time's up!") {
+		t.Errorf("got %q, want no completion", got)
+	}
+}
+
+func TestRunNotify(t *testing.T) {
+	// A fake notify-send that records its message.
+	dir := t.TempDir()
+	sent := filepath.Join(dir, "sent")
+	script := "#!/bin/sh\nfor last; do :; done\necho \"$last\" > " + sent + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "notify-send"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	out, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	if err := Run(context.Background(), Config{Duration: time.Second, Notify: false, Output: out}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sent); err == nil {
+		t.Fatal("notified without Notify")
+	}
+
+	if err := Run(context.Background(), Config{Duration: time.Second, Notify: true, Output: out}); err != nil {
+		t.Fatal(err)
+	}
+	// Run waits for the notification, so it must be there already.
+	if got, err := os.ReadFile(sent); err != nil || len(got) == 0 {
+		t.Errorf("got %q (%v), want the notification message", got, err)
+	}
+}
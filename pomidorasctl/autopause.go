@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+)
+
+// autopause pauses the timer while the screen is locked. Rather than the
+// server watching the desktop session, autopause runs a monitor command that
+// prints a line whenever the lock state changes and sends pause_all or
+// resume_all as those lines match --lock or --unlock:
+//
+//	pomidorasctl autopause --lock 'boolean true' --unlock 'boolean false' \
+//		'gdbus monitor --session --dest org.gnome.ScreenSaver --object-path /org/gnome/ScreenSaver'
+//	pomidorasctl autopause --lock '^LOCK' --unlock '^UNBLANK' 'xscreensaver-command -watch'
+//
+// By default a line containing the word "locked" or "lock" pauses and
+// "unlocked" or "unlock" resumes, so a script of your own can simply echo
+// those. Only a countdown is paused, and only a timer autopause paused is
+// resumed, so a timer paused by hand stays paused after unlocking. Start it
+// with the desktop session, for example from a systemd user unit; it exits
+// when the monitor command does. Its pauses give "screen locked" as their
+// reason unless --reason says otherwise.
+func autopause(args []string) {
+	fs := flag.NewFlagSet("autopause", flag.ExitOnError)
+	lockFlag := fs.String("lock", `\block(ed)?\b`, "regexp matching monitor output that means the screen locked")
+	unlockFlag := fs.String("unlock", `\bunlock(ed)?\b`, "regexp matching monitor output that means the screen unlocked")
+	reason := fs.String("reason", "screen locked", "pause reason shown in the status")
+	positional := parseInterspersed(fs, args)
+	if len(positional) != 1 {
+		fail("Usage: pomidorasctl autopause [--lock <regexp>] [--unlock <regexp>] [--reason <text>] <monitor command>")
+	}
+	lock, err := regexp.Compile(*lockFlag)
+	if err != nil {
+		fail("Invalid --lock:", err)
+	}
+	unlock, err := regexp.Compile(*unlockFlag)
+	if err != nil {
+		fail("Invalid --unlock:", err)
+	}
+
+	cmd := exec.Command("sh", "-c", positional[0])
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		fail("Error running monitor command:", err)
+	}
+	if err := cmd.Start(); err != nil {
+		fail("Error running monitor command:", err)
+	}
+	if err := followLockState(out, lock, unlock, *reason, sendRequest); err != nil {
+		fail("Error reading monitor command:", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		fail("Monitor command failed:", err)
+	}
+}
+
+// followLockState reads lock state lines from r until it ends, pausing the
+// timer for reason through send on lines matching lock and resuming it on lines matching
+// unlock if it was paused here. unlock is checked first, since a lock pattern
+// can easily match unlock lines too. Server errors are printed and the line
+// skipped, so a restarting server does not end autopause.
+func followLockState(r io.Reader, lock, unlock *regexp.Regexp, reason string, send func(Request) (Response, error)) error {
+	pausedHere := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var req Request
+		switch {
+		case unlock.MatchString(line):
+			if !pausedHere {
+				continue
+			}
+			req = Request{Type: RequestTypeResumeAll}
+		case lock.MatchString(line):
+			resp, err := send(Request{Type: RequestTypeStatus})
+			if err != nil || !resp.Success {
+				fmt.Fprintln(os.Stderr, "Error querying server:", serverError(resp, err))
+				continue
+			}
+			if resp.Status.State != StateCountdown {
+				continue
+			}
+			req = Request{Type: RequestTypePauseAll, Payload: reason}
+		default:
+			continue
+		}
+
+		resp, err := send(req)
+		if err != nil || !resp.Success {
+			fmt.Fprintln(os.Stderr, "Error querying server:", serverError(resp, err))
+			continue
+		}
+		pausedHere = req.Type == RequestTypePauseAll
+		printResult(resp)
+	}
+	return scanner.Err()
+}
+
+// serverError describes a failed request, whether it failed to reach the
+// server or the server refused it.
+func serverError(resp Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Message
+}
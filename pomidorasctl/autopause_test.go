@@ -0,0 +1,38 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFollowLockState(t *testing.T) {
+	lock := regexp.MustCompile(`\block(ed)?\b`)
+	unlock := regexp.MustCompile(`\bunlock(ed)?\b`)
+	tests := []struct {
+		name  string
+		state State
+		lines string
+		want  []RequestType
+	}{
+		{"lock and unlock", StateCountdown, "locked\nunlocked\n",
+			[]RequestType{RequestTypeStatus, RequestTypePauseAll, RequestTypeResumeAll}},
+		{"paused by hand stays paused", StatePaused, "locked\nunlocked\n",
+			[]RequestType{RequestTypeStatus}},
+		{"unlock without lock", StateCountdown, "unlock\nsomething else\n", nil},
+	}
+	for _, tt := range tests {
+		var sent []RequestType
+		send := func(req Request) (Response, error) {
+			sent = append(sent, req.Type)
+			return Response{Success: true, Status: TimerStatus{State: tt.state}}, nil
+		}
+		if err := followLockState(strings.NewReader(tt.lines), lock, unlock, "screen locked", send); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(sent, tt.want) {
+			t.Errorf("%s: sent %v, want %v", tt.name, sent, tt.want)
+		}
+	}
+}
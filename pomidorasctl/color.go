@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// ansiColors are the color names accepted in POMIDORAS_COLORS.
+var ansiColors = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+}
+
+// colorThreshold colors remaining times of at least Min.
+type colorThreshold struct {
+	Min   time.Duration
+	Color string
+}
+
+// defaultColors is green from 5 minutes, yellow from 1 minute and red below.
+const defaultColors = "5m=green,1m=yellow,0s=red"
+
+// parseColorThresholds parses a comma separated list of duration=color
+// pairs, such as defaultColors. Thresholds must be in decreasing order and
+// colors must be known names.
+func parseColorThresholds(s string) ([]colorThreshold, error) {
+	var thresholds []colorThreshold
+	for _, field := range strings.Split(s, ",") {
+		threshold, color, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not duration=color", field)
+		}
+		d, err := time.ParseDuration(threshold)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid threshold %q", threshold)
+		}
+		if _, ok := ansiColors[color]; !ok {
+			return nil, fmt.Errorf("unknown color %q", color)
+		}
+		if n := len(thresholds); n > 0 && d >= thresholds[n-1].Min {
+			return nil, fmt.Errorf("threshold %v is not below %v", d, thresholds[n-1].Min)
+		}
+		thresholds = append(thresholds, colorThreshold{Min: d, Color: color})
+	}
+	return thresholds, nil
+}
+
+// colorThresholdsFromEnv reads the thresholds from POMIDORAS_COLORS, falling
+// back to defaultColors with a warning if they are invalid.
+func colorThresholdsFromEnv() []colorThreshold {
+	value := os.Getenv("POMIDORAS_COLORS")
+	if value != "" {
+		thresholds, err := parseColorThresholds(value)
+		if err == nil {
+			return thresholds
+		}
+		fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_COLORS %q: %v, using %s\n", value, err, defaultColors)
+	}
+	thresholds, _ := parseColorThresholds(defaultColors)
+	return thresholds
+}
+
+// colorize wraps text in the color of the first threshold remaining reaches,
+// leaving it alone if there is none.
+func colorize(text string, remaining time.Duration, thresholds []colorThreshold) string {
+	for _, threshold := range thresholds {
+		if remaining >= threshold.Min {
+			return "\x1b[" + ansiColors[threshold.Color] + "m" + text + "\x1b[0m"
+		}
+	}
+	return text
+}
+
+// stdoutIsTerminal reports whether colors can be used on stdout.
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
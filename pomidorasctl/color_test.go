@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseColorThresholds(t *testing.T) {
+	thresholds, err := parseColorThresholds(defaultColors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []colorThreshold{{5 * time.Minute, "green"}, {time.Minute, "yellow"}, {0, "red"}}
+	if len(thresholds) != len(want) {
+		t.Fatalf("got %+v, want %+v", thresholds, want)
+	}
+	for i := range want {
+		if thresholds[i] != want[i] {
+			t.Errorf("threshold %d: got %+v, want %+v", i, thresholds[i], want[i])
+		}
+	}
+
+	for _, invalid := range []string{
+		"1m=yellow,5m=green", // Not decreasing
+		"5m=green,5m=red",    // Repeated
+		"5m=teal",            // Unknown color
+		"green",              // No threshold
+		"soon=red",           // Bad duration
+	} {
+		if _, err := parseColorThresholds(invalid); err == nil {
+			t.Errorf("%q: got no error", invalid)
+		}
+	}
+}
+
+func TestColorize(t *testing.T) {
+	thresholds, _ := parseColorThresholds("5m=green,1m=yellow")
+	tests := []struct {
+		remaining time.Duration
+		want      string
+	}{
+		{10 * time.Minute, "\x1b[32m10:00\x1b[0m"},
+		{5 * time.Minute, "\x1b[32m10:00\x1b[0m"},
+		{2 * time.Minute, "\x1b[33m10:00\x1b[0m"},
+		{30 * time.Second, "10:00"}, // Below every threshold
+	}
+	for _, tt := range tests {
+		if got := colorize("10:00", tt.remaining, thresholds); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.remaining, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// command describes a pomidorasctl command for the completion scripts.
+type command struct {
+	name  string
+	about string
+	flags []string // Without the leading dashes
+	words []string // Arguments to offer after the flags, like phase names
+	files bool     // The argument is a file
+}
+
+// statusFlagNames are the flags of the status display, which status and
+// watch take, and pomidorasctl on its own.
+var statusFlagNames = []string{"precise", "format", "compact", "verbose", "seconds", "elapsed", "idle-full"}
+
+// globalFlagNames work with every command, see main.
+var globalFlagNames = []string{"socket", "json"}
+
+// commands lists what main dispatches, for the completion scripts. A new
+// command needs an entry here to be completed.
+var commands = []command{
+	{name: "status", about: "show the remaining time", flags: statusFlagNames},
+	{name: "watch", about: "print the status whenever it changes", flags: statusFlagNames},
+	{name: "add", about: "add seconds or a duration to the running phase", flags: []string{"percent"}},
+	{name: "reset", about: "reset the timer, optionally to a duration"},
+	{name: "nudge", about: "skip to the next phase"},
+	{name: "start", about: "start a work session", flags: []string{"align"}},
+	{name: "pause", about: "pause every timer", flags: []string{"all", "reason"}},
+	{name: "resume", about: "resume every timer", flags: []string{"all"}},
+	{name: "fresh", about: "start a new work session", flags: []string{"yes", "quiet", "force"}},
+	{name: "set-work", about: "change the work length", flags: []string{"apply-now"}},
+	{name: "set-short-break", about: "change the short break length", flags: []string{"apply-now"}},
+	{name: "set-long-break", about: "change the long break length", flags: []string{"apply-now"}},
+	{name: "set-initial", about: "change the initial duration"},
+	{name: "next-work", about: "set the length of the next work session only"},
+	{name: "mute", about: "mute or unmute notifications and sounds", flags: []string{"toggle"}},
+	{name: "wait-phase", about: "wait until a phase is counting down", flags: []string{"timeout"}, words: sortedKeys(waitPhases)},
+	{name: "long-break-in", about: "estimate when the long break starts"},
+	{name: "next-reminder", about: "show when the next reminder fires"},
+	{name: "plan", about: "schedule a number of pomodoros", flags: []string{"json"}},
+	{name: "history", about: "export or clear the history", flags: []string{"csv", "clear", "yes"}},
+	{name: "import", about: "import history from CSV", files: true},
+	{name: "report", about: "chart the work of the last days", flags: []string{"week", "days"}},
+	{name: "streak", about: "show the current and longest streaks"},
+	{name: "goal", about: "show progress towards the daily goal"},
+	{name: "logs", about: "print the server log", flags: []string{"follow"}},
+	{name: "emoji", about: "print the progress as an emoji", flags: []string{"set", "idle"}},
+	{name: "ring", about: "draw the progress as a ring", flags: []string{"size"}},
+	{name: "autopause", about: "pause while the screen is locked", flags: []string{"lock", "unlock", "reason"}},
+	{name: "capabilities", about: "list what the server supports"},
+	{name: "info", about: "show the server version and uptime"},
+	{name: "whoami", about: "show how the server sees this client"},
+	{name: "ping", about: "check that the server answers"},
+	{name: "raw", about: "send a request and print the JSON response"},
+	{name: "completion", about: "print a shell completion script", words: []string{"bash", "zsh", "fish"}},
+}
+
+// flagWords are the values offered for flags that take one of a few.
+var flagWords = map[string][]string{
+	"format": {"plain", "waybar", "polybar"},
+	"set":    {"moon", "tomato"},
+}
+
+// completionScripts generate the script for each shell completion accepts.
+var completionScripts = map[string]func() string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// completion prints a completion script, loaded with, for example,
+// "source <(pomidorasctl completion bash)".
+func completion(args []string) {
+	if len(args) != 1 || completionScripts[args[0]] == nil {
+		fail("Usage: pomidorasctl completion bash|zsh|fish")
+	}
+	fmt.Print(completionScripts[args[0]]())
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// dashed returns flags as they are typed, with two dashes.
+func dashed(flags []string) []string {
+	out := make([]string, len(flags))
+	for i, flag := range flags {
+		out[i] = "--" + flag
+	}
+	return out
+}
+
+// commandWords returns what to offer after cmd: its flags, then its words.
+func commandWords(cmd command) string {
+	return strings.Join(append(dashed(cmd.flags), cmd.words...), " ")
+}
+
+// topWords returns what to offer before a command: the commands and the
+// flags pomidorasctl takes on its own.
+func topWords() string {
+	var words []string
+	for _, cmd := range commands {
+		words = append(words, cmd.name)
+	}
+	words = append(words, dashed(globalFlagNames)...)
+	words = append(words, dashed(statusFlagNames)...)
+	return strings.Join(words, " ")
+}
+
+func bashCompletion() string {
+	var b strings.Builder
+	b.WriteString(`# bash completion for pomidorasctl
+_pomidorasctl() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	local cmd= i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		--socket) ((i++)) ;;
+		-*) ;;
+		*) cmd=${COMP_WORDS[i]}; break ;;
+		esac
+	done
+	case $prev in
+	--socket) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+`)
+	for _, flag := range sortedKeys(flagWords) {
+		fmt.Fprintf(&b, "\t--%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", flag, strings.Join(flagWords[flag], " "))
+	}
+	b.WriteString("\tesac\n\tcase $cmd in\n")
+	fmt.Fprintf(&b, "\t'') COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", topWords())
+	for _, cmd := range commands {
+		words := commandWords(cmd)
+		switch {
+		case cmd.files:
+			fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -f -- \"$cur\")) ;;\n", cmd.name)
+		case words != "":
+			fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", cmd.name, words)
+		}
+	}
+	b.WriteString(`	esac
+}
+complete -F _pomidorasctl pomidorasctl
+`)
+	return b.String()
+}
+
+func zshCompletion() string {
+	var b strings.Builder
+	b.WriteString(`#compdef pomidorasctl
+_pomidorasctl() {
+	local -a commands
+	commands=(
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t\t'%s:%s'\n", cmd.name, cmd.about)
+	}
+	b.WriteString(`	)
+	local cmd= i
+	for ((i = 2; i < CURRENT; i++)); do
+		case $words[i] in
+		--socket) ((i++)) ;;
+		-*) ;;
+		*) cmd=$words[i]; break ;;
+		esac
+	done
+	case $words[CURRENT-1] in
+	--socket) _files; return ;;
+`)
+	for _, flag := range sortedKeys(flagWords) {
+		fmt.Fprintf(&b, "\t--%s) compadd -- %s; return ;;\n", flag, strings.Join(flagWords[flag], " "))
+	}
+	b.WriteString("\tesac\n\tcase $cmd in\n")
+	fmt.Fprintf(&b, "\t'') _describe command commands; compadd -- %s ;;\n",
+		strings.Join(append(dashed(globalFlagNames), dashed(statusFlagNames)...), " "))
+	for _, cmd := range commands {
+		words := commandWords(cmd)
+		switch {
+		case cmd.files:
+			fmt.Fprintf(&b, "\t%s) _files ;;\n", cmd.name)
+		case words != "":
+			fmt.Fprintf(&b, "\t%s) compadd -- %s ;;\n", cmd.name, words)
+		}
+	}
+	b.WriteString(`	esac
+}
+if [[ $funcstack[1] == _pomidorasctl ]]; then
+	_pomidorasctl "$@"
+else
+	compdef _pomidorasctl pomidorasctl
+fi
+`)
+	return b.String()
+}
+
+func fishCompletion() string {
+	var b strings.Builder
+	b.WriteString(`# fish completion for pomidorasctl
+complete -c pomidorasctl -f
+complete -c pomidorasctl -l socket -r -F -d 'server socket or address'
+complete -c pomidorasctl -l json -d 'print JSON'
+`)
+	for _, flag := range statusFlagNames {
+		fmt.Fprintf(&b, "complete -c pomidorasctl -n __fish_use_subcommand -l %s%s\n", flag, fishFlagWords(flag))
+	}
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c pomidorasctl -n __fish_use_subcommand -a %s -d '%s'\n", cmd.name, cmd.about)
+	}
+	for _, cmd := range commands {
+		seen := "'__fish_seen_subcommand_from " + cmd.name + "'"
+		for _, flag := range cmd.flags {
+			fmt.Fprintf(&b, "complete -c pomidorasctl -n %s -l %s%s\n", seen, flag, fishFlagWords(flag))
+		}
+		if len(cmd.words) > 0 {
+			fmt.Fprintf(&b, "complete -c pomidorasctl -n %s -a '%s'\n", seen, strings.Join(cmd.words, " "))
+		}
+		if cmd.files {
+			fmt.Fprintf(&b, "complete -c pomidorasctl -n %s -F\n", seen)
+		}
+	}
+	return b.String()
+}
+
+// fishFlagWords returns the arguments completing the values of flag, if it
+// takes one of a few.
+func fishFlagWords(flag string) string {
+	if words, ok := flagWords[flag]; ok {
+		return fmt.Sprintf(" -x -a '%s'", strings.Join(words, " "))
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	for shell, script := range completionScripts {
+		text := script()
+		for _, cmd := range commands {
+			if !strings.Contains(text, cmd.name) {
+				t.Errorf("%s script does not complete %s", shell, cmd.name)
+			}
+		}
+
+		// Check the syntax where the shell is installed
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			continue
+		}
+		file := filepath.Join(t.TempDir(), "pomidorasctl."+shell)
+		if err := os.WriteFile(file, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command(path, "-n", file).CombinedOutput(); err != nil {
+			t.Errorf("%s script: %v\n%s", shell, err, out)
+		}
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("no bash:", err)
+	}
+	complete := func(words ...string) []string {
+		script := bashCompletion() + `
+COMP_WORDS=("$@")
+COMP_CWORD=$(($# - 1))
+_pomidorasctl
+printf '%s\n' "${COMPREPLY[@]}"
+`
+		out, err := exec.Command(bash, append([]string{"-c", script, "bash", "pomidorasctl"}, words...)...).Output()
+		if err != nil {
+			t.Fatalf("completing %q: %v", words, err)
+		}
+		return strings.Fields(string(out))
+	}
+
+	for _, test := range []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"wa"}, "watch wait-phase"},
+		{[]string{"--socket", "/tmp/s", "--com"}, "--compact"},
+		{[]string{"wait-phase", "b"}, "break"},
+		{[]string{"--json", "report", "--"}, "--week --days"},
+		{[]string{"status", "--format", ""}, "plain waybar polybar"},
+		{[]string{"completion", "z"}, "zsh"},
+	} {
+		if got := strings.Join(complete(test.words...), " "); got != test.want {
+			t.Errorf("completing %q: got %q, want %q", test.words, got, test.want)
+		}
+	}
+}
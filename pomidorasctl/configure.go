@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// ConfigurePayload mirrors the server's payload for RequestTypeConfigure.
+type ConfigurePayload struct {
+	Work              string `json:"work,omitempty"`
+	ShortBreak        string `json:"short_break,omitempty"`
+	LongBreak         string `json:"long_break,omitempty"`
+	LongBreakInterval *int   `json:"long_break_interval,omitempty"`
+	BreakStartDelay   string `json:"break_start_delay,omitempty"`
+	ApplyNow          bool   `json:"apply_now,omitempty"`
+}
+
+// setCommands maps the set-* commands to the payload field they set.
+var setCommands = map[string]func(*ConfigurePayload, string){
+	"set-work":        func(p *ConfigurePayload, d string) { p.Work = d },
+	"set-short-break": func(p *ConfigurePayload, d string) { p.ShortBreak = d },
+	"set-long-break":  func(p *ConfigurePayload, d string) { p.LongBreak = d },
+}
+
+// errUsage is returned by parseDurationArg when the arguments are not a
+// single duration.
+var errUsage = errors.New("wrong number of arguments")
+
+// parseDurationArg parses fs from args and returns the single duration left,
+// or with "-" the one read from stdin. A duration starting with a dash is
+// taken for a flag, so "set-work -- -1m" is how to write one, and negative
+// durations are refused here rather than by the server.
+func parseDurationArg(fs *flag.FlagSet, args []string) (string, error) {
+	positional := parseInterspersed(fs, args)
+	if len(positional) != 1 {
+		return "", errUsage
+	}
+	value, err := resolvePayload(positional[0])
+	if err != nil {
+		return "", err
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid duration %q", value)
+	}
+	if d < 0 {
+		return "", fmt.Errorf("duration %q is negative", value)
+	}
+	return value, nil
+}
+
+// setDuration changes one phase length on the server, such as
+// "pomidorasctl set-work 50m", or with "-" the duration read from stdin. The
+// running phase keeps its length unless --apply-now is given.
+func setDuration(command string, args []string) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	applyNow := fs.Bool("apply-now", false, "also change the length of the running phase")
+	value, err := parseDurationArg(fs, args)
+	if errors.Is(err, errUsage) {
+		failf("Usage: pomidorasctl %s [--apply-now] <duration>\n", command)
+	}
+	if err != nil {
+		fail("Invalid argument:", err)
+	}
+
+	payload := ConfigurePayload{ApplyNow: *applyNow}
+	setCommands[command](&payload, value)
+	data, _ := json.Marshal(payload)
+
+	resp, err := sendRequest(Request{Type: RequestTypeConfigure, Payload: string(data)})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	printResult(resp)
+}
+
+// setInitial changes the length of future work sessions, such as
+// "pomidorasctl set-initial 25m", without touching the running countdown.
+func setInitial(args []string) {
+	value, err := parseDurationArg(flag.NewFlagSet("set-initial", flag.ExitOnError), args)
+	if errors.Is(err, errUsage) {
+		fail("Usage: pomidorasctl set-initial <duration>")
+	}
+	if err != nil {
+		fail("Invalid argument:", err)
+	}
+
+	resp, err := sendRequest(Request{Type: RequestTypeSetInitial, Payload: value})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	printResult(resp)
+}
+
+// nextWork makes only the next work session last the given duration, such
+// as "pomidorasctl next-work 50m" for one deep-work block, after which the
+// configured length applies again. 0 drops the override.
+func nextWork(args []string) {
+	value, err := parseDurationArg(flag.NewFlagSet("next-work", flag.ExitOnError), args)
+	if errors.Is(err, errUsage) {
+		fail("Usage: pomidorasctl next-work <duration>")
+	}
+	if err != nil {
+		fail("Invalid argument:", err)
+	}
+
+	resp, err := sendRequest(Request{Type: RequestTypeNextWork, Payload: value})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	printResult(resp)
+}
+
+// mute flips whether the server mutes notifications and sounds with
+// "pomidorasctl mute --toggle", in one request so a toggle button does not
+// have to check the status first, and prints the new state.
+func mute(args []string) {
+	fs := flag.NewFlagSet("mute", flag.ExitOnError)
+	toggle := fs.Bool("toggle", false, "mute if unmuted, unmute if muted")
+	fs.Parse(args)
+	if !*toggle || fs.NArg() != 0 {
+		fail("Usage: pomidorasctl mute --toggle")
+	}
+
+	resp, err := sendRequest(Request{Type: RequestTypeToggleMute})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success || resp.Muted == nil {
+		fail("Server error:", resp.Message)
+	}
+	if jsonOutput {
+		printJSON(muteResult{Muted: *resp.Muted})
+		return
+	}
+	fmt.Println(resp.Message)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestParseDurationArg(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{[]string{"50m"}, "50m", false},
+		{[]string{"--apply-now", "--", "50m"}, "50m", false},
+		{[]string{"--", "-1m"}, "", true},
+		{[]string{"soon"}, "", true},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("set-work", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Bool("apply-now", false, "")
+		got, err := parseDurationArg(fs, tt.args)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%q: got %q, %v", tt.args, got, err)
+		}
+	}
+
+	fs := flag.NewFlagSet("set-initial", flag.ContinueOnError)
+	if _, err := parseDurationArg(fs, nil); !errors.Is(err, errUsage) {
+		t.Errorf("no arguments: got %v, want errUsage", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// token is sent with every request, see pomidoras-server --tcp. Servers only
+// check it on TCP connections.
+var token = os.Getenv("POMIDORAS_TOKEN")
+
+// Defaults for POMIDORAS_DIAL_RETRIES and POMIDORAS_DIAL_RETRY_DELAY, enough
+// to ride out a server restart.
+const (
+	defaultDialRetries    = 3
+	defaultDialRetryDelay = 100 * time.Millisecond
+)
+
+// dial connects to the server at SocketPath, which is a Unix socket path or,
+// for a server started with --tcp, tcp://host:port or tls://host:port. TLS
+// certificates are checked against the system roots; SSL_CERT_FILE adds a
+// self-signed one.
+//
+// While the server restarts its socket is briefly missing or refusing
+// connections, so those failures are retried, POMIDORAS_DIAL_RETRIES times
+// POMIDORAS_DIAL_RETRY_DELAY apart, before giving up.
+func dial() (net.Conn, error) {
+	retries, delay := dialRetriesFromEnv()
+	return dialWithRetry(dialOnce, retries, delay)
+}
+
+func dialOnce() (net.Conn, error) {
+	if addr, ok := strings.CutPrefix(SocketPath, "tcp://"); ok {
+		return net.Dial("tcp", addr)
+	}
+	if addr, ok := strings.CutPrefix(SocketPath, "tls://"); ok {
+		return tls.Dial("tcp", addr, nil)
+	}
+	return net.Dial("unix", SocketPath)
+}
+
+// dialWithRetry calls connect until it succeeds, fails for a reason other
+// than the server being down, or has been retried retries times.
+func dialWithRetry(connect func() (net.Conn, error), retries int, delay time.Duration) (net.Conn, error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := connect()
+		if err == nil || attempt >= retries || !serverDown(err) {
+			return conn, err
+		}
+		time.Sleep(delay)
+	}
+}
+
+// serverDown reports whether err means nothing is listening yet, as opposed
+// to a problem retrying will not fix, such as a permission error.
+func serverDown(err error) bool {
+	return errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// dialRetriesFromEnv reads POMIDORAS_DIAL_RETRIES and
+// POMIDORAS_DIAL_RETRY_DELAY, warning about and ignoring invalid values.
+// POMIDORAS_DIAL_RETRIES=0 fails straight away.
+func dialRetriesFromEnv() (int, time.Duration) {
+	retries, delay := defaultDialRetries, defaultDialRetryDelay
+	if value := os.Getenv("POMIDORAS_DIAL_RETRIES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_DIAL_RETRIES %q, using %d\n", value, retries)
+		} else {
+			retries = n
+		}
+	}
+	if value := os.Getenv("POMIDORAS_DIAL_RETRY_DELAY"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid POMIDORAS_DIAL_RETRY_DELAY %q, using %v\n", value, delay)
+		} else {
+			delay = d
+		}
+	}
+	return retries, delay
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDialWithRetryRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomidoras.sock")
+	go func() {
+		time.Sleep(150 * time.Millisecond) // The server coming back up
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t.Cleanup(func() { listener.Close() })
+	}()
+
+	conn, err := dialWithRetry(func() (net.Conn, error) { return net.Dial("unix", path) }, 10, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("got %v, want a connection once the socket exists", err)
+	}
+	conn.Close()
+}
+
+func TestDialWithRetryGivesUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sock")
+	attempts := 0
+	_, err := dialWithRetry(func() (net.Conn, error) {
+		attempts++
+		return net.Dial("unix", path)
+	}, 3, time.Millisecond)
+	if err == nil || attempts != 4 {
+		t.Errorf("got %v after %d attempts, want an error after 4", err, attempts)
+	}
+
+	// Errors other than the server being down are not retried.
+	attempts = 0
+	dialErr := errors.New("permission denied")
+	_, err = dialWithRetry(func() (net.Conn, error) {
+		attempts++
+		return nil, dialErr
+	}, 3, time.Millisecond)
+	if err != dialErr || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want %v after 1", err, attempts, dialErr)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// emojiSets are the sequences emoji can pick from by name, from empty to
+// full.
+var emojiSets = map[string][]string{
+	"moon":   {"🌑", "🌘", "🌗", "🌖", "🌕"},
+	"tomato": {"🌱", "🍏", "🍅"},
+}
+
+type emojiResult struct {
+	Emoji string `json:"emoji"`
+}
+
+// pickEmoji returns the emoji in sequence, ordered from empty to full, for
+// the fraction of the phase remaining.
+func pickEmoji(sequence []string, remaining float64) string {
+	i := int(math.Round(remaining * float64(len(sequence)-1)))
+	return sequence[min(max(i, 0), len(sequence)-1)]
+}
+
+// emoji prints a single emoji that empties as the current phase counts down,
+// for minimalist status bars. --set picks one of emojiSets, or a comma
+// separated sequence of your own from empty to full.
+func emoji(args []string) {
+	fs := flag.NewFlagSet("emoji", flag.ExitOnError)
+	set := fs.String("set", "moon", "moon, tomato, or emoji from empty to full separated by commas")
+	idle := fs.String("idle", "⚪", "emoji to print when no phase is counting down")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fail("Usage: pomidorasctl emoji [--set <name or emoji,...>] [--idle <emoji>]")
+	}
+	sequence, ok := emojiSets[*set]
+	if !ok {
+		sequence = strings.Split(*set, ",")
+	}
+
+	resp, err := sendRequest(Request{Type: RequestTypeStatus})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	text := *idle
+	if status := resp.Status; status.State == StateCountdown || status.State == StatePaused {
+		text = pickEmoji(sequence, 1-progress(status))
+	}
+	if jsonOutput {
+		printJSON(emojiResult{Emoji: text})
+		return
+	}
+	fmt.Println(text)
+}
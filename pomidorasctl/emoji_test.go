@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestPickEmoji(t *testing.T) {
+	moon := emojiSets["moon"]
+	tests := []struct {
+		remaining float64
+		want      string
+	}{
+		{1, "🌕"},
+		{0.8, "🌖"},
+		{0.5, "🌗"},
+		{0.1, "🌑"},
+		{0, "🌑"},
+	}
+	for _, tt := range tests {
+		if got := pickEmoji(moon, tt.remaining); got != tt.want {
+			t.Errorf("pickEmoji(moon, %v) = %s, want %s", tt.remaining, got, tt.want)
+		}
+	}
+	if got := pickEmoji([]string{"x"}, 0.5); got != "x" {
+		t.Errorf("single emoji sequence: got %s", got)
+	}
+}
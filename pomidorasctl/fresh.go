@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fresh replaces whatever is running with a new work session, such as
+// "pomidorasctl fresh 50m", or of the configured work length without a
+// duration. It asks before replacing a running session unless --yes or
+// --quiet is given, and prints the new status.
+func fresh(args []string) {
+	fs := flag.NewFlagSet("fresh", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "do not ask before replacing a running session")
+	quiet := fs.Bool("quiet", false, "do not ask, and print nothing on success")
+	force := fs.Bool("force", false, "override the server's focus lock")
+	positional := parseInterspersed(fs, args)
+	if len(positional) > 1 {
+		fail("Usage: pomidorasctl fresh [--yes] [--quiet] [--force] [duration]")
+	}
+	req := Request{Type: RequestTypeSetDuration, Force: *force}
+	if len(positional) == 1 {
+		req.Payload = positional[0]
+		if d, err := time.ParseDuration(req.Payload); err != nil || d <= 0 {
+			failf("Invalid duration %q.\n", req.Payload)
+		}
+	}
+
+	if !*yes && !*quiet {
+		resp, err := sendRequest(Request{Type: RequestTypeStatus})
+		if err != nil {
+			fail("Error querying server:", err)
+		}
+		if !resp.Success {
+			fail("Server error:", resp.Message)
+		}
+		status := resp.Status
+		running := status.State == StateCountdown || status.State == StatePaused || status.State == StateStartingBreak
+		question := fmt.Sprintf("Replace the running %s with %s left?",
+			strings.ReplaceAll(status.Phase, "_", " "), formatRemaining(status.Duration))
+		if running && !confirm(question) {
+			printMessage("Aborted.")
+			return
+		}
+	}
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	if !*quiet {
+		printStatus(resp.Status)
+	}
+}
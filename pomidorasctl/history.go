@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// exportHistoryCSV prints every history record on the server as CSV.
+func exportHistoryCSV() {
+	resp, err := sendRequest(Request{Type: RequestTypeHistory})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	if err := writeHistoryCSV(os.Stdout, resp.History); err != nil {
+		fail("Error writing CSV:", err)
+	}
+}
+
+// exportHistoryJSON prints every history record on the server as a JSON array.
+func exportHistoryJSON() {
+	resp, err := sendRequest(Request{Type: RequestTypeHistory})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	records := resp.History
+	if records == nil {
+		records = []HistoryRecord{}
+	}
+	printJSON(records)
+}
+
+// writeHistoryCSV writes records with a header row and the columns
+// timestamp (RFC 3339), phase and duration_seconds.
+func writeHistoryCSV(w io.Writer, records []HistoryRecord) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"timestamp", "phase", "duration_seconds"})
+	for _, record := range records {
+		phase := record.Phase
+		if phase == "" {
+			phase = "work" // Recorded before phases existed, when everything was work
+		}
+		out.Write([]string{
+			record.Timestamp.Format(time.RFC3339),
+			phase,
+			strconv.Itoa(int(record.Duration.Seconds())),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
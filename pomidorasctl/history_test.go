@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHistoryCSV(t *testing.T) {
+	zone := time.FixedZone("EET", 2*60*60)
+	records := []HistoryRecord{
+		{Timestamp: time.Date(2024, 3, 1, 14, 0, 0, 0, zone), Phase: "work", Duration: 25 * time.Minute},
+		{Timestamp: time.Date(2024, 3, 1, 14, 5, 0, 0, zone), Phase: "short_break", Duration: 5 * time.Minute},
+		{Timestamp: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC), Duration: 90 * time.Second},
+	}
+
+	var out strings.Builder
+	if err := writeHistoryCSV(&out, records); err != nil {
+		t.Fatal(err)
+	}
+	want := "timestamp,phase,duration_seconds\n" +
+		"2024-03-01T14:00:00+02:00,work,1500\n" +
+		"2024-03-01T14:05:00+02:00,short_break,300\n" +
+		"2024-02-01T09:00:00Z,work,90\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importHistory sends the sessions in a CSV file, or stdin for "-", to the
+// server to append to its history. Malformed rows are skipped with a
+// warning.
+func importHistory(args []string) {
+	if len(args) != 1 {
+		fail("Usage: pomidorasctl import <file.csv|->")
+	}
+	var in io.Reader = stdin
+	if args[0] != stdinPayload {
+		file, err := os.Open(args[0])
+		if err != nil {
+			fail("Error opening file:", err)
+		}
+		defer file.Close()
+		in = file
+	}
+	records, warnings, err := parseImportCSV(in, time.Local)
+	if err != nil {
+		fail("Error reading CSV:", err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+	if len(records) == 0 {
+		fail("No sessions to import.")
+	}
+
+	data, _ := json.Marshal(records)
+	resp, err := sendRequest(Request{Type: RequestTypeImportHistory, Payload: string(data)})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	message := resp.Message
+	if len(warnings) > 0 {
+		message += fmt.Sprintf(" Skipped %d malformed rows.", len(warnings))
+	}
+	printMessage(message)
+}
+
+// parseImportCSV reads sessions as rows of timestamp,duration, or
+// timestamp,phase,duration as written by "history --csv". A header row is
+// skipped. Timestamps are RFC 3339, or "2006-01-02 15:04[:05]" in loc;
+// durations are seconds or Go durations like 25m. Malformed rows are left
+// out and described in warnings.
+func parseImportCSV(r io.Reader, loc *time.Location) (records []HistoryRecord, warnings []string, err error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1 // Checked per row, so a bad row is only a warning
+	in.TrimLeadingSpace = true
+	for first := true; ; first = false {
+		row, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			return records, warnings, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if first && strings.EqualFold(row[0], "timestamp") {
+			continue
+		}
+		line, _ := in.FieldPos(0)
+		record, err := parseImportRow(row, loc)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: %v, skipping", line, err))
+			continue
+		}
+		records = append(records, record)
+	}
+}
+
+// parseImportRow parses one row for parseImportCSV.
+func parseImportRow(row []string, loc *time.Location) (HistoryRecord, error) {
+	var record HistoryRecord
+	var duration string
+	switch len(row) {
+	case 2:
+		duration = row[1]
+	case 3:
+		record.Phase = row[1]
+		duration = row[2]
+	default:
+		return record, fmt.Errorf("want 2 or 3 columns, got %d", len(row))
+	}
+
+	timestamp, err := parseImportTime(row[0], loc)
+	if err != nil {
+		return record, err
+	}
+	if timestamp.After(time.Now()) {
+		return record, fmt.Errorf("timestamp %q is in the future", row[0])
+	}
+	record.Timestamp = timestamp
+	switch record.Phase {
+	case "", "work", "short_break", "long_break":
+	default:
+		return record, fmt.Errorf("unknown phase %q", record.Phase)
+	}
+	if seconds, err := strconv.Atoi(duration); err == nil {
+		record.Duration = time.Duration(seconds) * time.Second
+	} else if record.Duration, err = time.ParseDuration(duration); err != nil {
+		return record, fmt.Errorf("invalid duration %q", duration)
+	}
+	if record.Duration <= 0 {
+		return record, fmt.Errorf("invalid duration %q", duration)
+	}
+	return record, nil
+}
+
+// parseImportTime parses an RFC 3339 timestamp, or a local one without the
+// zone.
+func parseImportTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	zone := time.FixedZone("EET", 2*60*60)
+	in := "timestamp,phase,duration_seconds\n" +
+		"2024-03-01T14:00:00+02:00,work,1500\n" +
+		"2024-03-01 15:00,25m\n" +
+		"2024-03-01 15:30:10,short_break,300\n" +
+		"yesterday,1500\n" +
+		"2024-03-01T16:00:00Z,nap,1500\n" +
+		"2024-03-01T16:00:00Z,-5\n" +
+		"2999-01-01T00:00:00Z,1500\n" +
+		"2024-03-01T16:00:00Z\n"
+
+	records, warnings, err := parseImportCSV(strings.NewReader(in), zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HistoryRecord{
+		{Timestamp: time.Date(2024, 3, 1, 14, 0, 0, 0, zone), Phase: "work", Duration: 25 * time.Minute},
+		{Timestamp: time.Date(2024, 3, 1, 15, 0, 0, 0, zone), Duration: 25 * time.Minute},
+		{Timestamp: time.Date(2024, 3, 1, 15, 30, 10, 0, zone), Phase: "short_break", Duration: 5 * time.Minute},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records %+v, want %d", len(records), records, len(want))
+	}
+	for i := range want {
+		if !records[i].Timestamp.Equal(want[i].Timestamp) || records[i].Phase != want[i].Phase || records[i].Duration != want[i].Duration {
+			t.Errorf("record %d: got %+v, want %+v", i, records[i], want[i])
+		}
+	}
+	if len(warnings) != 5 || !strings.HasPrefix(warnings[0], "line 5: ") {
+		t.Errorf("got warnings %q, want 5 starting at line 5", warnings)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+type LogLine struct {
+	Seq  int64  `json:"seq"`
+	Text string `json:"text"`
+}
+
+// logs prints the server's recent output. With --follow it keeps asking for
+// lines after the last one seen, which the server holds until there are some.
+// The server keeps POMIDORAS_LOG_BUFFER lines, so a follower that falls that
+// far behind skips the lines in between.
+func logs(args []string) {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := fs.Bool("follow", false, "keep printing new lines as the server writes them")
+	fs.Parse(args)
+
+	req := Request{Type: RequestTypeLogs}
+	for {
+		resp, err := sendRequest(req)
+		if err != nil {
+			fail("Error querying server:", err)
+		}
+		if !resp.Success {
+			fail("Server error:", resp.Message)
+		}
+		for _, line := range resp.Logs {
+			if jsonOutput {
+				printJSON(line)
+			} else {
+				fmt.Println(line.Text)
+			}
+			req.Payload = strconv.FormatInt(line.Seq, 10)
+		}
+		if !*follow {
+			return
+		}
+		if req.Payload == "" {
+			req.Payload = "0" // Nothing yet; wait for the first line
+		}
+	}
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,12 +21,41 @@ type State string
 const (
 	StateCountdown State = "countdown"
 	StateIdle      State = "idle"
-	SocketPath           = "/tmp/pomidoras.sock" // Must match the server's socket path
+	StatePaused    State = "paused"
+	StateScheduled State = "scheduled"
+
+	StateStartingBreak State = "starting_break"
 )
 
+// defaultPauseReason is the server's reason for a pause without one, which
+// the status does not bother showing.
+const defaultPauseReason = "manual"
+
+// SocketPath is where the server listens. It must be resolved the same way
+// as the server's: $XDG_RUNTIME_DIR/pomidoras.sock when XDG_RUNTIME_DIR is
+// set, /tmp/pomidoras.sock otherwise. --socket overrides both, and may also
+// name a TCP server, see dial.
+var SocketPath = defaultSocketPath()
+
+func defaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "pomidoras.sock")
+	}
+	return "/tmp/pomidoras.sock"
+}
+
 type TimerStatus struct {
-	State    State         `json:"state"`
-	Duration time.Duration `json:"duration"`
+	State        State         `json:"state"`
+	Duration     time.Duration `json:"duration"`
+	Phase        string        `json:"phase,omitempty"`
+	FocusedToday time.Duration `json:"focused_today,omitempty"`
+	StartsIn     time.Duration `json:"starts_in,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	Flash        bool          `json:"flash,omitempty"`
+	PauseReason  string        `json:"pause_reason,omitempty"`
+
+	InitialDuration time.Duration `json:"initial_duration,omitempty"`
+	CycleRemaining  time.Duration `json:"cycle_remaining,omitempty"`
 }
 
 // Request types for client-server communication
@@ -28,75 +65,991 @@ const (
 	RequestTypeStatus     RequestType = "status"
 	RequestTypeAddSeconds RequestType = "add_seconds"
 	RequestTypeReset      RequestType = "reset" // Added reset request
+
+	RequestTypeClearHistory RequestType = "clear_history"
+	RequestTypeNudge        RequestType = "nudge"
+	RequestTypeLongBreakIn  RequestType = "long_break_in"
+	RequestTypeNextReminder RequestType = "next_reminder"
+
+	RequestTypeLongPollStatus RequestType = "long_poll_status"
+	RequestTypeGetRemaining   RequestType = "get_remaining"
+	RequestTypePauseAll       RequestType = "pause_all"
+	RequestTypeResumeAll      RequestType = "resume_all"
+	RequestTypeGetConfig      RequestType = "get_config"
+	RequestTypeStart          RequestType = "start"
+	RequestTypeHistory        RequestType = "history"
+	RequestTypeStreak         RequestType = "streak"
+	RequestTypeGoal           RequestType = "goal"
+	RequestTypeStats          RequestType = "stats"
+	RequestTypeLogs           RequestType = "logs"
+	RequestTypeCapabilities   RequestType = "capabilities"
+	RequestTypeAddPercent     RequestType = "add_percent"
+	RequestTypeConfigure      RequestType = "configure"
+	RequestTypePing           RequestType = "ping"
+	RequestTypeInfo           RequestType = "info"
+	RequestTypeSetInitial     RequestType = "set_initial"
+	RequestTypeImportHistory  RequestType = "import_history"
+	RequestTypeWhoami         RequestType = "whoami"
+	RequestTypeSetDuration    RequestType = "set_duration"
+	RequestTypeNextWork       RequestType = "next_work"
+	RequestTypeToggleMute     RequestType = "toggle_mute"
+	RequestTypeSubscribe      RequestType = "subscribe"
 )
 
 type Request struct {
 	Type    RequestType `json:"type"`
 	Payload string      `json:"payload,omitempty"` // Use string for flexibility
+	Force   bool        `json:"force,omitempty"`   // Override the focus lock
+	Token   string      `json:"token,omitempty"`   // See token
 }
 
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Status  TimerStatus `json:"status,omitempty"`
+
+	Remaining *int   `json:"remaining,omitempty"`
+	State     string `json:"state,omitempty"`
+	Muted     *bool  `json:"muted,omitempty"`
+	Changed   *bool  `json:"changed,omitempty"`
+
+	LongBreak *LongBreakEstimate `json:"long_break,omitempty"`
+	Reminder  *ReminderEstimate  `json:"reminder,omitempty"`
+	Config    *PhaseDurations    `json:"config,omitempty"`
+	History   []HistoryRecord    `json:"history,omitempty"`
+	Streak    *Streak            `json:"streak,omitempty"`
+	Goal      *Goal              `json:"goal,omitempty"`
+	Stats     []DayTotal         `json:"stats,omitempty"`
+	Logs      []LogLine          `json:"logs,omitempty"`
+
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	Info         *Info         `json:"info,omitempty"`
+	Whoami       *Whoami       `json:"whoami,omitempty"`
+
+	Event     *Event `json:"event,omitempty"`     // Streamed after RequestTypeSubscribe
+	Heartbeat bool   `json:"heartbeat,omitempty"` // A subscription is alive
 }
 
-func main() {
-	conn, err := net.Dial("unix", SocketPath)
+type Info struct {
+	Version   string        `json:"version"`
+	StartedAt time.Time     `json:"started_at"`
+	Uptime    time.Duration `json:"uptime"`
+}
+
+type Whoami struct {
+	UID        *int   `json:"uid,omitempty"`
+	ServerUID  int    `json:"server_uid"`
+	Remote     bool   `json:"remote"`
+	Authorized bool   `json:"authorized"`
+	Protocol   string `json:"protocol"`
+}
+
+type Capabilities struct {
+	RequestTypes []RequestType   `json:"request_types"`
+	Features     map[string]bool `json:"features"`
+}
+
+type HistoryRecord struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Phase     string        `json:"phase,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+type Streak struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+}
+
+type Goal struct {
+	Completed int `json:"completed"`
+	Target    int `json:"target"`
+	Percent   int `json:"percent"`
+}
+
+type DayTotal struct {
+	Date     time.Time     `json:"date"`
+	Sessions int           `json:"sessions"`
+	Focused  time.Duration `json:"focused"`
+}
+
+type LongBreakEstimate struct {
+	Enabled  bool          `json:"enabled"`
+	Sessions int           `json:"sessions"`
+	In       time.Duration `json:"in"`
+	At       time.Time     `json:"at"`
+}
+
+// Event is a timer event streamed to a subscription.
+type Event struct {
+	Type      string        `json:"type"`
+	Phase     string        `json:"phase,omitempty"`
+	Remaining time.Duration `json:"remaining"`
+	At        time.Time     `json:"at"`
+}
+
+type ReminderEstimate struct {
+	Left time.Duration `json:"left"`
+	In   time.Duration `json:"in"`
+	At   time.Time     `json:"at"`
+}
+
+// confirm asks a yes/no question on stdin and reports whether the answer was yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Display options for the status command, which is also what runs when
+// pomidorasctl is given only flags.
+var (
+	statusFlags = flag.NewFlagSet("status", flag.ExitOnError)
+	precise     = statusFlags.Bool("precise", false, "show remaining time with milliseconds (MM:SS.mmm)")
+	format      = statusFlags.String("format", "plain", "output format: plain, waybar or polybar")
+	compact     = statusFlags.Bool("compact", false, "drop leading zeros (4:05, or 55 under a minute)")
+	verbose     = statusFlags.Bool("verbose", false, "also show the phase, start time and time focused today")
+	seconds     = statusFlags.Bool("seconds", false, "print only the remaining whole seconds (0 when idle)")
+	elapsed     = statusFlags.Bool("elapsed", false, "show the time counted so far instead of the time remaining")
+	idleFull    = statusFlags.Bool("idle-full", false, "with --elapsed, show the initial duration when idle rather than 00:00")
+)
+
+// stdinPayload as an add or set-* value means "read it from stdin", so
+// computed values need no shell quoting: echo 300 | pomidorasctl add -
+const stdinPayload = "-"
+
+// stdin is where stdinPayload values come from; stdinRead is set once it was
+// used up.
+var (
+	stdin     io.Reader = os.Stdin
+	stdinRead bool
+)
+
+// resolvePayload returns arg, or for stdinPayload all of stdin with
+// surrounding whitespace trimmed. Stdin can only be read once.
+func resolvePayload(arg string) (string, error) {
+	if arg != stdinPayload {
+		return arg, nil
+	}
+	if stdinRead {
+		return "", errors.New("stdin can only be read once")
+	}
+	stdinRead = true
+	data, err := io.ReadAll(stdin)
 	if err != nil {
-		fmt.Println("Error connecting to server:", err)
-		os.Exit(1)
+		return "", fmt.Errorf("reading stdin: %v", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", errors.New("no value on stdin")
+	}
+	return value, nil
+}
+
+// phaseClass groups server phases into the classes used by bar formats.
+func phaseClass(status TimerStatus) string {
+	switch {
+	case status.State == StatePaused:
+		return "paused"
+	case status.State == StateScheduled:
+		return "scheduled"
+	case status.State == StateStartingBreak:
+		return "break"
+	case status.State != StateCountdown:
+		return "idle"
+	case status.Phase == "short_break" || status.Phase == "long_break":
+		return "break"
+	default:
+		return "work"
+	}
+}
+
+var phaseIcons = map[string]string{
+	"work":  "🍅",
+	"break": "☕",
+}
+
+// printStatus writes the status in the format selected with --format.
+func printStatus(status TimerStatus) {
+	if jsonOutput {
+		printJSON(status)
+		return
+	}
+	shown := status.Duration
+	if *elapsed {
+		shown = status.InitialDuration - status.Duration
+	}
+	text := "Idle"
+	switch status.State {
+	case StateIdle:
+		if *elapsed {
+			text = formatRemaining(idleElapsed())
+		}
+	case StateCountdown:
+		text = formatRemaining(shown)
+	case StatePaused:
+		text = "Paused " + formatRemaining(shown)
+		if status.PauseReason != "" && status.PauseReason != defaultPauseReason {
+			text = fmt.Sprintf("Paused (%s) %s", status.PauseReason, formatRemaining(shown))
+		}
+	case StateScheduled:
+		text = "Starting in " + formatRemaining(status.StartsIn)
+	case StateStartingBreak:
+		text = "Starting break in " + formatRemaining(status.StartsIn)
+	}
+	class := phaseClass(status)
+
+	switch *format {
+	case "waybar":
+		// Custom module JSON, see waybar-custom(5).
+		tooltip := class
+		if status.Phase != "" && class != "idle" {
+			tooltip = strings.ReplaceAll(status.Phase, "_", " ")
+		}
+		// A phase that just completed also gets the flash class, for CSS to
+		// animate.
+		var classes any = class
+		if status.Flash {
+			classes = []string{class, "flash"}
+		}
+		out, _ := json.Marshal(struct {
+			Text    string `json:"text"`
+			Tooltip string `json:"tooltip"`
+			Class   any    `json:"class"`
+		}{Text: text, Tooltip: tooltip, Class: classes})
+		fmt.Println(string(out))
+	case "polybar":
+		if icon, ok := phaseIcons[class]; ok {
+			text = icon + " " + text
+		}
+		if status.Flash {
+			text = "%{R}" + text + "%{R}" // Swapped colors until the flash ends
+		}
+		fmt.Println(text)
+	default:
+		if status.State == StateCountdown && stdoutIsTerminal() {
+			text = colorize(text, status.Duration, colorThresholdsFromEnv())
+		}
+		fmt.Println(text)
+	}
+
+	if *verbose && *format == "plain" {
+		if status.State == StateCountdown || status.State == StateStartingBreak {
+			fmt.Println("phase:", strings.ReplaceAll(status.Phase, "_", " "))
+		}
+		if status.CycleRemaining > 0 {
+			fmt.Println("cycle ends in", formatRemaining(status.CycleRemaining))
+		}
+		if !status.StartedAt.IsZero() {
+			fmt.Println("started:", status.StartedAt.Local().Format("15:04"))
+		}
+		if status.PauseReason != "" {
+			fmt.Println("paused:", status.PauseReason)
+		}
+		fmt.Println("focused today:", formatHoursMinutes(status.FocusedToday))
+	}
+}
+
+// idleElapsed is what --elapsed shows while idle: nothing counted yet, or
+// with --idle-full the initial duration a session started now would count.
+func idleElapsed() time.Duration {
+	if !*idleFull {
+		return 0
+	}
+	resp, err := sendRequest(Request{Type: RequestTypeGetConfig})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success || resp.Config == nil {
+		fail("Server error:", resp.Message)
+	}
+	return resp.Config.Work
+}
+
+// formatHoursMinutes renders d to the minute, like 2h15m or 40m.
+func formatHoursMinutes(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func parseStatusFlags(args []string) {
+	statusFlags.Parse(args)
+	switch *format {
+	case "plain", "waybar", "polybar":
+	default:
+		failf("Unknown format %q.\n", *format)
+	}
+}
+
+// formatRemaining renders d as MM:SS, or MM:SS.mmm with --precise. With
+// --compact minutes lose their leading zero and disappear under a minute.
+func formatRemaining(d time.Duration) string {
+	minutes := int(d.Minutes())
+	seconds := int(d.Seconds()) % 60
+
+	var text string
+	switch {
+	case !*compact:
+		text = fmt.Sprintf("%02d:%02d", minutes, seconds)
+	case minutes == 0:
+		text = fmt.Sprintf("%d", seconds)
+	default:
+		text = fmt.Sprintf("%d:%02d", minutes, seconds)
+	}
+	if *precise {
+		text += fmt.Sprintf(".%03d", d.Milliseconds()%1000)
+	}
+	return text
+}
+
+// printReminder prints when the next reminder fires and at what remaining
+// time, or "none" if every reminder of the session has fired.
+func printReminder(estimate *ReminderEstimate) {
+	if jsonOutput {
+		printJSON(estimate)
+		return
+	}
+	if estimate == nil {
+		fmt.Println("none")
+		return
+	}
+	fmt.Printf("%s (%s left, in %s)\n", estimate.At.Local().Format("15:04:05"),
+		formatRemaining(estimate.Left), estimate.In.Round(time.Second))
+}
+
+// printStreak prints the current and longest streaks of days with a
+// completed work session.
+func printStreak(streak *Streak) {
+	if streak == nil {
+		streak = &Streak{}
+	}
+	if jsonOutput {
+		printJSON(streak)
+		return
+	}
+	fmt.Printf("Current streak: %s\n", pluralDays(streak.Current))
+	fmt.Printf("Longest streak: %s\n", pluralDays(streak.Longest))
+}
+
+// printGoal prints the work sessions completed today against the daily
+// goal, like 5/8 (62%).
+func printGoal(goal *Goal) {
+	if goal == nil {
+		goal = &Goal{}
+	}
+	if jsonOutput {
+		printJSON(goal)
+		return
+	}
+	if goal.Target == 0 {
+		fmt.Printf("%d completed today, no daily goal set (POMIDORAS_DAILY_GOAL)\n", goal.Completed)
+		return
+	}
+	fmt.Printf("%d/%d (%d%%)\n", goal.Completed, goal.Target, goal.Percent)
+}
+
+func pluralDays(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
+
+func printLongBreak(estimate *LongBreakEstimate) {
+	if jsonOutput {
+		if estimate == nil {
+			estimate = &LongBreakEstimate{}
+		}
+		printJSON(estimate)
+		return
+	}
+	switch {
+	case estimate == nil || !estimate.Enabled:
+		fmt.Println("Long breaks are disabled.")
+	case estimate.Sessions == 0:
+		fmt.Println("On a long break now.")
+	case estimate.Sessions == 1:
+		fmt.Printf("1 work session until the next long break, at about %s (in %s).\n",
+			estimate.At.Local().Format("15:04"), estimate.In.Round(time.Second))
+	default:
+		fmt.Printf("%d work sessions until the next long break, at about %s (in %s).\n",
+			estimate.Sessions, estimate.At.Local().Format("15:04"), estimate.In.Round(time.Second))
+	}
+}
+
+// printCapabilities lists the request types and features a server reported.
+// Servers from before capabilities existed reject the request instead.
+func printCapabilities(capabilities *Capabilities) {
+	if jsonOutput {
+		printJSON(capabilities)
+		return
+	}
+	if capabilities == nil {
+		fmt.Println("The server did not report its capabilities.")
+		return
+	}
+	fmt.Println("Request types:")
+	for _, reqType := range capabilities.RequestTypes {
+		fmt.Println(" ", reqType)
+	}
+
+	fmt.Println("Features:")
+	names := make([]string, 0, len(capabilities.Features))
+	for name := range capabilities.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		state := "no"
+		if capabilities.Features[name] {
+			state = "yes"
+		}
+		fmt.Printf("  %s: %s\n", name, state)
+	}
+}
+
+// alignDelay returns how long to wait from now until the next multiple of
+// align since local midnight, or 0 if now is already on one.
+func alignDelay(now time.Time, align time.Duration) time.Duration {
+	sinceMidnight := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	if rest := sinceMidnight % align; rest != 0 {
+		return align - rest
+	}
+	return 0
+}
+
+// sendRequest sends a single request to the server and decodes its response.
+func sendRequest(req Request) (Response, error) {
+	conn, err := dial()
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+
+	req.Token = token
+	if err := json.NewEncoder(conn).Encode(&req); err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	err = json.NewDecoder(conn).Decode(&resp)
+	return resp, err
+}
+
+// batchVerbs are the commands that can be chained in a single invocation,
+// such as "pomidorasctl reset add 300". The -a and -r flags are accepted as
+// aliases for add and reset, and "add --percent 10" adds a percentage of the
+// initial duration instead of seconds. add also takes durations such as 5m,
+// and negative values subtract: "add -300", or "add -- -5m" to make sure
+// the value is never taken for a flag. reset may be followed by a duration
+// that becomes the new initial duration, 0 to leave the timer idle.
+var batchVerbs = map[string]RequestType{
+	"status": RequestTypeStatus,
+	"add":    RequestTypeAddSeconds,
+	"-a":     RequestTypeAddSeconds,
+	"reset":  RequestTypeReset,
+	"-r":     RequestTypeReset,
+	"nudge":  RequestTypeNudge,
+}
+
+// parseBatch turns a list of verbs into requests, left to right. Commas
+// between verbs are ignored. --continue-on-error and --force (override the
+// server's focus lock) may appear anywhere before a -- and apply to the
+// whole batch.
+func parseBatch(args []string) (reqs []Request, continueOnError bool, err error) {
+	var words []string
+	force := false
+	flags := true // Until --
+	for _, arg := range args {
+		if flags && arg == "--" {
+			flags = false
+			continue
+		}
+		if flags && arg == "--continue-on-error" {
+			continueOnError = true
+			continue
+		}
+		if flags && arg == "--force" {
+			force = true
+			continue
+		}
+		if word := strings.Trim(arg, ","); word != "" {
+			words = append(words, word)
+		}
+	}
+
+	for i := 0; i < len(words); i++ {
+		reqType, ok := batchVerbs[words[i]]
+		if !ok {
+			return nil, false, fmt.Errorf("unknown command %q", words[i])
+		}
+		req := Request{Type: reqType, Force: force}
+		if reqType == RequestTypeAddSeconds {
+			verb := words[i]
+			if i+1 < len(words) && words[i+1] == "--percent" {
+				// add --percent 10 adds 10% of the initial duration
+				req.Type = RequestTypeAddPercent
+				i++
+			}
+			if i+1 >= len(words) {
+				return nil, false, fmt.Errorf("%s needs a number of seconds or --percent and a percentage", verb)
+			}
+			i++
+			payload, err := resolvePayload(words[i])
+			if err == nil && req.Type == RequestTypeAddSeconds {
+				payload, err = addSeconds(payload)
+			}
+			if err != nil {
+				return nil, false, fmt.Errorf("%s: %v", verb, err)
+			}
+			req.Payload = payload
+		}
+		if reqType == RequestTypeReset && i+1 < len(words) {
+			if _, verb := batchVerbs[words[i+1]]; !verb {
+				i++
+				payload, err := resetDuration(words[i])
+				if err != nil {
+					return nil, false, fmt.Errorf("%s: %v", words[i-1], err)
+				}
+				req.Payload = payload
+			}
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, continueOnError, nil
+}
+
+// addSeconds turns the value of add into the whole seconds the server
+// expects, accepting durations such as -5m as well as seconds.
+func addSeconds(value string) (string, error) {
+	if _, err := strconv.Atoi(value); err == nil {
+		return value, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid number of seconds or duration %q", value)
+	}
+	return strconv.Itoa(int(d / time.Second)), nil
+}
+
+// resetDuration checks the optional duration of reset, which may be read
+// from stdin. Negative durations are refused here rather than by the server.
+func resetDuration(value string) (string, error) {
+	value, err := resolvePayload(value)
+	if err != nil {
+		return "", err
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid duration %q", value)
+	}
+	if d < 0 {
+		return "", fmt.Errorf("duration %q is negative", value)
+	}
+	return value, nil
+}
+
+// runBatch sends every request over one connection, printing each response.
+// It stops at the first failure unless continueOnError is set, and exits
+// non-zero if anything failed.
+func runBatch(args []string) {
+	reqs, continueOnError, err := parseBatch(args)
+	if err != nil {
+		fail("Invalid argument:", err)
+	}
+
+	conn, err := dial()
+	if err != nil {
+		fail("Error connecting to server:", err)
 	}
 	defer conn.Close()
 
 	encoder := json.NewEncoder(conn)
 	decoder := json.NewDecoder(conn)
 
+	failed := false
+	for _, req := range reqs {
+		req.Token = token
+		if err := encoder.Encode(&req); err != nil {
+			fail("Error sending request:", err)
+		}
+		var resp Response
+		if err := decoder.Decode(&resp); err != nil {
+			fail("Error receiving response:", err)
+		}
+
+		switch {
+		case !resp.Success:
+			printError("Server error:", resp.Message)
+			failed = true
+		case req.Type == RequestTypeStatus:
+			printStatus(resp.Status)
+		default:
+			printResult(resp)
+		}
+		if failed && !continueOnError {
+			break
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// statusKey is what the server's status_since request compares: the state,
+// the phase, the whole seconds remaining and the flash hint.
+type statusKey struct {
+	State     State
+	Phase     string
+	Remaining int
+	Flash     bool
+}
+
+func keyOf(status TimerStatus) statusKey {
+	return statusKey{State: status.State, Phase: status.Phase, Remaining: int(status.Duration.Seconds()), Flash: status.Flash}
+}
+
+// watch prints the status every time it changes, using long-poll requests so
+// the server does the waiting. Wake-ups that leave the status the same to
+// the second, see statusKey, are not printed again.
+func watch(args []string) {
+	parseStatusFlags(args)
+	var last *statusKey
+	for {
+		resp, err := sendRequest(Request{Type: RequestTypeLongPollStatus})
+		if err != nil {
+			fail("Error querying server:", err)
+		}
+		if !resp.Success {
+			fail("Server error:", resp.Message)
+		}
+		if key := keyOf(resp.Status); last == nil || key != *last {
+			printStatus(resp.Status)
+			last = &key
+		}
+	}
+}
+
+// waitPhases maps the names accepted by wait-phase to the server phases they match.
+var waitPhases = map[string][]string{
+	"work":        {"work"},
+	"break":       {"short_break", "long_break"},
+	"short_break": {"short_break"},
+	"long_break":  {"long_break"},
+}
+
+// waitPhase waits until the server counts down the named phase, returning
+// straight away if it already is. It follows the server's events rather than
+// polling, so a phase is noticed however briefly it runs.
+func waitPhase(args []string) {
+	fs := flag.NewFlagSet("wait-phase", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 0, "give up after this long (0 waits forever)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fail("Usage: pomidorasctl wait-phase [--timeout <duration>] work|break|short_break|long_break")
+	}
+	name := fs.Arg(0)
+	phases, ok := waitPhases[name]
+	if !ok {
+		failf("Unknown phase %q.\n", name)
+	}
+
+	conn, err := dial()
+	if err != nil {
+		fail("Error connecting to server:", err)
+	}
+	defer conn.Close()
+	if *timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(*timeout))
+	}
+	decoder := json.NewDecoder(conn)
+	receive := func() Response {
+		var resp Response
+		if err := decoder.Decode(&resp); errors.Is(err, os.ErrDeadlineExceeded) {
+			failf("Timed out waiting for %s.\n", name)
+		} else if err != nil {
+			fail("Error receiving response:", err)
+		}
+		if !resp.Success {
+			fail("Server error:", resp.Message)
+		}
+		return resp
+	}
+	inPhase := func() bool {
+		resp, err := sendRequest(Request{Type: RequestTypeStatus})
+		if err != nil {
+			fail("Error querying server:", err)
+		}
+		if !resp.Success {
+			fail("Server error:", resp.Message)
+		}
+		return resp.Status.State == StateCountdown && slices.Contains(phases, resp.Status.Phase)
+	}
+
+	// Subscribed before the first look, so no change slips in between.
+	if err := json.NewEncoder(conn).Encode(&Request{Type: RequestTypeSubscribe, Token: token}); err != nil {
+		fail("Error sending request:", err)
+	}
+	receive()
+	if inPhase() {
+		return
+	}
+	for {
+		event := receive().Event
+		switch {
+		case event == nil:
+			// A heartbeat
+		case event.Type == "tick":
+			// Ticks only come while counting down.
+			if slices.Contains(phases, event.Phase) {
+				return
+			}
+		case inPhase():
+			return
+		}
+	}
+}
+
+// takeSocketFlag removes --socket <path> or --socket=<path> from args,
+// wherever it appears, and returns the remaining args with the path, which is
+// empty if the flag was not given. A missing path is reported as an error.
+func takeSocketFlag(args []string) ([]string, string, error) {
+	var rest []string
+	path := ""
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--socket":
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("--socket needs a path")
+			}
+			i++
+			path = args[i]
+		case strings.HasPrefix(arg, "--socket="):
+			path = strings.TrimPrefix(arg, "--socket=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, path, nil
+}
+
+func main() {
+	// --socket overrides the socket path for this invocation, taking
+	// precedence over XDG_RUNTIME_DIR and the default.
+	args, socket, err := takeSocketFlag(os.Args[1:])
+	if err != nil {
+		fail("Invalid argument:", err)
+	}
+	if socket != "" {
+		SocketPath = socket
+	}
+	// --json likewise applies to whichever command it is given with, see
+	// jsonOutput for what each one prints.
+	args, jsonOutput = takeJSONFlag(args)
+	os.Args = append(os.Args[:1], args...)
+
 	var req Request
+	raw := false // Print the response JSON as received
 	if len(os.Args) > 1 {
-		switch os.Args[1] {
-		case "-a":
+		switch strings.TrimSuffix(os.Args[1], ",") {
+		case "-a", "-r", "add", "reset", "nudge", "--continue-on-error", "--force":
+			runBatch(os.Args[1:])
+			return
+		case "history":
+			fs := flag.NewFlagSet("history", flag.ExitOnError)
+			clearHistory := fs.Bool("clear", false, "delete all history records")
+			yes := fs.Bool("yes", false, "do not ask for confirmation")
+			csvOut := fs.Bool("csv", false, "print all history records as CSV")
+			fs.Parse(os.Args[2:])
+			if *csvOut && !*clearHistory {
+				exportHistoryCSV()
+				return
+			}
+			if jsonOutput && !*clearHistory && !*csvOut {
+				exportHistoryJSON()
+				return
+			}
+			if !*clearHistory || *csvOut {
+				fail("Usage: pomidorasctl history --csv | --json | --clear [--yes]")
+			}
+			if !*yes && !confirm("Clear all pomodoro history?") {
+				printMessage("Aborted.")
+				return
+			}
+			req = Request{Type: RequestTypeClearHistory}
+		case "raw":
 			if len(os.Args) < 3 {
-				fmt.Println("Usage: pomidoras_client -a <seconds>")
-				os.Exit(1)
+				fail("Usage: pomidorasctl raw <type> [payload]")
 			}
-			req = Request{Type: RequestTypeAddSeconds, Payload: os.Args[2]}
-		case "-r": // Handle reset flag
-			req = Request{Type: RequestTypeReset}
+			req = Request{Type: RequestType(os.Args[2])}
+			if len(os.Args) > 3 {
+				req.Payload = os.Args[3]
+			}
+			raw = true
+		case "pause", "resume":
+			// Every timer is affected; --all is accepted to make that explicit.
+			fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+			fs.Bool("all", false, "apply to every timer on the server")
+			var reason *string
+			if os.Args[1] == "pause" {
+				reason = fs.String("reason", "", "why, shown in the status, like meeting")
+			}
+			fs.Parse(os.Args[2:])
+			req = Request{Type: RequestTypePauseAll}
+			if os.Args[1] == "resume" {
+				req.Type = RequestTypeResumeAll
+			} else {
+				req.Payload = *reason
+			}
+		case "start":
+			fs := flag.NewFlagSet("start", flag.ExitOnError)
+			align := fs.Duration("align", 0, "start on the next multiple of this duration since midnight, like 15m")
+			fs.Parse(os.Args[2:])
+			if *align < 0 {
+				fail("Invalid alignment.")
+			}
+			req = Request{Type: RequestTypeStart}
+			if *align > 0 {
+				req.Payload = alignDelay(time.Now(), *align).String()
+			}
+		case "long-break-in":
+			req = Request{Type: RequestTypeLongBreakIn}
+		case "next-reminder":
+			req = Request{Type: RequestTypeNextReminder}
+		case "streak":
+			req = Request{Type: RequestTypeStreak}
+		case "goal":
+			req = Request{Type: RequestTypeGoal}
+		case "capabilities":
+			req = Request{Type: RequestTypeCapabilities}
+		case "ping":
+			ping()
+			return
+		case "info":
+			info()
+			return
+		case "whoami":
+			whoami()
+			return
+		case "plan":
+			plan(os.Args[2:])
+			return
+		case "set-work", "set-short-break", "set-long-break":
+			setDuration(os.Args[1], os.Args[2:])
+			return
+		case "fresh":
+			fresh(os.Args[2:])
+			return
+		case "set-initial":
+			setInitial(os.Args[2:])
+			return
+		case "next-work":
+			nextWork(os.Args[2:])
+			return
+		case "mute":
+			mute(os.Args[2:])
+			return
+		case "import":
+			importHistory(os.Args[2:])
+			return
+		case "logs":
+			logs(os.Args[2:])
+			return
+		case "report":
+			report(os.Args[2:])
+			return
+		case "emoji":
+			emoji(os.Args[2:])
+			return
+		case "autopause":
+			autopause(os.Args[2:])
+			return
+		case "watch":
+			watch(os.Args[2:])
+			return
+		case "ring":
+			ring(os.Args[2:])
+			return
+		case "wait-phase":
+			waitPhase(os.Args[2:])
+			return
+		case "completion":
+			completion(os.Args[2:])
+			return
+		case "status":
+			parseStatusFlags(os.Args[2:])
+			req = Request{Type: RequestTypeStatus}
 		default:
-			fmt.Println("Invalid argument.")
-			os.Exit(1)
+			if !strings.HasPrefix(os.Args[1], "--") {
+				fail("Invalid argument.")
+			}
+			parseStatusFlags(os.Args[1:])
+			req = Request{Type: RequestTypeStatus}
 		}
 	} else {
 		req = Request{Type: RequestTypeStatus}
 	}
 
+	if req.Type == RequestTypeStatus && *seconds {
+		req.Type = RequestTypeGetRemaining // Skip the full status
+	}
+
+	conn, err := dial()
+	if err != nil {
+		fail("Error connecting to server:", err)
+	}
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	req.Token = token
 	if err := encoder.Encode(&req); err != nil {
-		fmt.Println("Error sending request:", err)
-		os.Exit(1)
+		fail("Error sending request:", err)
+	}
+
+	if raw {
+		var msg json.RawMessage
+		if err := decoder.Decode(&msg); err != nil {
+			fail("Error receiving response:", err)
+		}
+		fmt.Println(string(msg))
+		return
 	}
 
 	var resp Response
 	if err := decoder.Decode(&resp); err != nil {
-		fmt.Println("Error receiving response:", err)
-		os.Exit(1)
+		fail("Error receiving response:", err)
 	}
 
 	if !resp.Success {
-		fmt.Println("Server error:", resp.Message)
-		os.Exit(1)
+		fail("Server error:", resp.Message)
 	}
 
-	if req.Type == RequestTypeStatus {
-		if resp.Status.State == StateCountdown {
-			minutes := int(resp.Status.Duration.Minutes())
-			seconds := int(resp.Status.Duration.Seconds()) % 60
-			fmt.Printf("%02d:%02d\n", minutes, seconds)
+	if req.Type == RequestTypeGetRemaining {
+		if resp.Remaining == nil {
+			fail("Server error:", resp.Message)
+		}
+		if jsonOutput {
+			printJSON(remainingResult{Remaining: *resp.Remaining})
 		} else {
-			fmt.Println("Idle")
+			fmt.Println(*resp.Remaining)
 		}
+	} else if req.Type == RequestTypeStatus {
+		printStatus(resp.Status)
+	} else if req.Type == RequestTypeLongBreakIn {
+		printLongBreak(resp.LongBreak)
+	} else if req.Type == RequestTypeNextReminder {
+		printReminder(resp.Reminder)
+	} else if req.Type == RequestTypeStreak {
+		printStreak(resp.Streak)
+	} else if req.Type == RequestTypeGoal {
+		printGoal(resp.Goal)
+	} else if req.Type == RequestTypeCapabilities {
+		printCapabilities(resp.Capabilities)
 	} else {
-		fmt.Println(resp.Message) // Print server's success/failure message
+		printResult(resp) // Print server's success/failure message
 	}
 }
-
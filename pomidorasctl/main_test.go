@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlignDelay(t *testing.T) {
+	tests := []struct {
+		now   string
+		align time.Duration
+		want  time.Duration
+	}{
+		{"14:02:00", 15 * time.Minute, 13 * time.Minute},
+		{"14:44:30", 15 * time.Minute, 30 * time.Second},
+		{"14:45:00", 15 * time.Minute, 0},
+		{"14:50:00", time.Hour, 10 * time.Minute},
+		{"23:59:00", 30 * time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		clock, err := time.Parse("15:04:05", tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Date(2024, 3, 1, clock.Hour(), clock.Minute(), clock.Second(), 0, time.Local)
+		if got := alignDelay(now, tt.align); got != tt.want {
+			t.Errorf("alignDelay(%s, %v) = %v, want %v", tt.now, tt.align, got, tt.want)
+		}
+	}
+}
+
+func TestTakeSocketFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		rest []string
+		path string
+	}{
+		{[]string{"status"}, []string{"status"}, ""},
+		{[]string{"--socket", "/run/a.sock", "status", "--compact"}, []string{"status", "--compact"}, "/run/a.sock"},
+		{[]string{"add", "60", "--socket=/run/b.sock"}, []string{"add", "60"}, "/run/b.sock"},
+	}
+	for _, tt := range tests {
+		rest, path, err := takeSocketFlag(tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if strings.Join(rest, " ") != strings.Join(tt.rest, " ") || path != tt.path {
+			t.Errorf("%q: got %q and %q, want %q and %q", tt.args, rest, path, tt.rest, tt.path)
+		}
+	}
+
+	if _, _, err := takeSocketFlag([]string{"status", "--socket"}); err == nil {
+		t.Error("--socket without a path: got no error")
+	}
+}
+
+func TestTakeJSONFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		rest []string
+		json bool
+	}{
+		{[]string{"status"}, []string{"status"}, false},
+		{[]string{"--json", "info"}, []string{"info"}, true},
+		{[]string{"add", "60", "--json"}, []string{"add", "60"}, true},
+		{[]string{"plan", "--json", "4"}, []string{"plan", "4"}, true},
+	}
+	for _, tt := range tests {
+		rest, found := takeJSONFlag(tt.args)
+		if strings.Join(rest, " ") != strings.Join(tt.rest, " ") || found != tt.json {
+			t.Errorf("%q: got %q and %v, want %q and %v", tt.args, rest, found, tt.rest, tt.json)
+		}
+	}
+}
+
+func TestParseBatchAddPercent(t *testing.T) {
+	reqs, _, err := parseBatch([]string{"add", "--percent", "10,", "add", "60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Request{
+		{Type: RequestTypeAddPercent, Payload: "10"},
+		{Type: RequestTypeAddSeconds, Payload: "60"},
+	}
+	if len(reqs) != len(want) || reqs[0] != want[0] || reqs[1] != want[1] {
+		t.Errorf("got %+v, want %+v", reqs, want)
+	}
+
+	if _, _, err := parseBatch([]string{"add", "--percent"}); err == nil {
+		t.Error("add --percent without a percentage: got no error")
+	}
+}
+
+func TestParseBatchStdin(t *testing.T) {
+	defer func() { stdin, stdinRead = os.Stdin, false }()
+
+	stdin, stdinRead = strings.NewReader(" 300\n"), false
+	reqs, _, err := parseBatch([]string{"add", "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Request{Type: RequestTypeAddSeconds, Payload: "300"}); len(reqs) != 1 || reqs[0] != want {
+		t.Errorf("got %+v, want %+v", reqs, want)
+	}
+
+	stdin, stdinRead = strings.NewReader("10\n"), false
+	if _, _, err := parseBatch([]string{"add", "-", "add", "-"}); err == nil {
+		t.Error("reading stdin twice: got no error")
+	}
+	stdin, stdinRead = strings.NewReader("\n"), false
+	if _, _, err := parseBatch([]string{"add", "--percent", "-"}); err == nil {
+		t.Error("empty stdin: got no error")
+	}
+}
+
+func TestParseBatchNegative(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"add", "-300"}, "-300"},
+		{[]string{"add", "--", "-300"}, "-300"},
+		{[]string{"add", "--", "-5m"}, "-300"},
+		{[]string{"-a", "90s"}, "90"},
+	}
+	for _, tt := range tests {
+		reqs, _, err := parseBatch(tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if want := (Request{Type: RequestTypeAddSeconds, Payload: tt.want}); len(reqs) != 1 || reqs[0] != want {
+			t.Errorf("%q: got %+v, want %+v", tt.args, reqs, want)
+		}
+	}
+
+	// After -- batch flags are values too, and not valid ones for add.
+	if _, _, err := parseBatch([]string{"add", "--", "--force"}); err == nil {
+		t.Error("add -- --force: got no error")
+	}
+	if _, _, err := parseBatch([]string{"add", "soon"}); err == nil {
+		t.Error("add soon: got no error")
+	}
+}
+
+func TestParseBatchReset(t *testing.T) {
+	reqs, _, err := parseBatch([]string{"reset", "50m", "reset", "add", "60", "-r", "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Request{
+		{Type: RequestTypeReset, Payload: "50m"},
+		{Type: RequestTypeReset},
+		{Type: RequestTypeAddSeconds, Payload: "60"},
+		{Type: RequestTypeReset, Payload: "0"},
+	}
+	if !slices.Equal(reqs, want) {
+		t.Errorf("got %+v, want %+v", reqs, want)
+	}
+
+	for _, args := range [][]string{{"reset", "--", "-5m"}, {"reset", "soon"}} {
+		if _, _, err := parseBatch(args); err == nil {
+			t.Errorf("%q: got no error", args)
+		}
+	}
+}
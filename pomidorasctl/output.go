@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// jsonOutput is set by --json anywhere on the command line. Every command
+// then prints JSON instead of text, one document per line:
+//
+//	status, watch, fresh  the status object as the server sends it
+//	status --seconds      {"remaining": <seconds>}
+//	add, reset, set-*...  {"message": "<server message>", "changed": <bool>}
+//	long-break-in         the long break estimate object
+//	next-reminder         the reminder estimate object, or null
+//	streak                {"current": <days>, "longest": <days>}
+//	goal                  {"completed": <n>, "target": <n>, "percent": <n>}
+//	report                {"days": [{"date", "sessions", "focused"}...], "sessions": <n>, "focused": <ns>}
+//	capabilities          the capabilities object
+//	history               the records as an array
+//	history --clear       {"message": "<server message>", "changed": <bool>}
+//	import                {"message": "<server message>"}, warnings on stderr
+//	plan                  the schedule as an array
+//	logs                  {"seq": <n>, "text": "<line>"} per line
+//	ring                  {"remaining": <ns>, "progress": <0 to 1>} per update
+//	emoji                 {"emoji": "<emoji>"}
+//	mute --toggle         {"muted": <bool>}
+//	ping                  {"latency_ms": <milliseconds>}
+//	info                  {"version": "...", "started_at": "<RFC 3339>", "uptime_seconds": <n>}
+//	whoami                the whoami object as the server sends it
+//
+// Errors print {"error": "<message>"} and exit with status 1. Durations are
+// nanoseconds, as in the protocol, unless the field name says otherwise.
+var jsonOutput bool
+
+type messageResult struct {
+	Message string `json:"message"`
+	Changed *bool  `json:"changed,omitempty"` // Left out by servers that do not say
+}
+
+type errorResult struct {
+	Error string `json:"error"`
+}
+
+type remainingResult struct {
+	Remaining int `json:"remaining"`
+}
+
+type muteResult struct {
+	Muted bool `json:"muted"`
+}
+
+type pingResult struct {
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+type infoResult struct {
+	Version       string    `json:"version"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int       `json:"uptime_seconds"`
+}
+
+// takeJSONFlag removes every --json from args and reports whether there
+// was one.
+func takeJSONFlag(args []string) ([]string, bool) {
+	var rest []string
+	found := false
+	for _, arg := range args {
+		if arg == "--json" {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, found
+}
+
+// printJSON prints v as a single line of JSON.
+func printJSON(v any) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error encoding JSON:", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+}
+
+// printMessage prints a server message, such as "Added 60 seconds.".
+func printMessage(message string) {
+	if jsonOutput {
+		printJSON(messageResult{Message: message})
+		return
+	}
+	fmt.Println(message)
+}
+
+// printResult prints the reply to a request that can change the timer,
+// pointing out when the server says it did not, such as when resetting an
+// idle timer to idle.
+func printResult(resp Response) {
+	if jsonOutput {
+		printJSON(messageResult{Message: resp.Message, Changed: resp.Changed})
+		return
+	}
+	if resp.Changed != nil && !*resp.Changed {
+		fmt.Println(resp.Message, "No change.")
+		return
+	}
+	fmt.Println(resp.Message)
+}
+
+// printError reports a failure without exiting, as text or with --json as
+// an errorResult. The arguments are formatted like fmt.Println.
+func printError(a ...any) {
+	text := strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+	if jsonOutput {
+		printJSON(errorResult{Error: text})
+		return
+	}
+	fmt.Println(text)
+}
+
+// fail reports a failure like printError and exits with status 1.
+func fail(a ...any) {
+	printError(a...)
+	os.Exit(1)
+}
+
+// failf is fail with a format string.
+func failf(format string, a ...any) {
+	fail(strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"))
+}
+
+// ping checks that the server answers and how quickly.
+func ping() {
+	start := time.Now()
+	resp, err := sendRequest(Request{Type: RequestTypePing})
+	latency := time.Since(start)
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	if jsonOutput {
+		printJSON(pingResult{LatencyMS: float64(latency.Microseconds()) / 1000})
+		return
+	}
+	fmt.Printf("%s from %s in %s\n", resp.Message, SocketPath, latency.Round(time.Microsecond))
+}
+
+// info prints the server version and how long it has been running.
+func info() {
+	resp, err := sendRequest(Request{Type: RequestTypeInfo})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success || resp.Info == nil {
+		fail("Server error:", resp.Message)
+	}
+	if jsonOutput {
+		printJSON(infoResult{
+			Version:       resp.Info.Version,
+			StartedAt:     resp.Info.StartedAt,
+			UptimeSeconds: int(resp.Info.Uptime.Seconds()),
+		})
+		return
+	}
+	fmt.Println("version:", resp.Info.Version)
+	fmt.Printf("up %s, since %s\n", resp.Info.Uptime, resp.Info.StartedAt.Local().Format("2006-01-02 15:04"))
+}
+
+// whoami prints how the server sees this connection, to debug socket
+// permissions and POMIDORAS_TOKEN.
+func whoami() {
+	resp, err := sendRequest(Request{Type: RequestTypeWhoami})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success || resp.Whoami == nil {
+		fail("Server error:", resp.Message)
+	}
+	w := resp.Whoami
+	if jsonOutput {
+		printJSON(w)
+		return
+	}
+	fmt.Println("server:", SocketPath)
+	if w.UID != nil {
+		fmt.Printf("uid: %d, server uid: %d\n", *w.UID, w.ServerUID)
+	} else {
+		fmt.Printf("uid: unknown, server uid: %d\n", w.ServerUID)
+	}
+	switch {
+	case w.Authorized:
+		fmt.Println("token: accepted")
+	case token != "":
+		// Remote servers refuse wrong tokens outright, so this is local.
+		fmt.Println("token: wrong, but not needed on this connection")
+	default:
+		fmt.Println("token: not needed")
+	}
+	fmt.Println("protocol:", w.Protocol)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PhaseDurations mirrors the server's work/break configuration.
+type PhaseDurations struct {
+	Work              time.Duration `json:"work"`
+	ShortBreak        time.Duration `json:"short_break"`
+	LongBreak         time.Duration `json:"long_break"`
+	LongBreakInterval int           `json:"long_break_interval"`
+	BreakStartDelay   time.Duration `json:"break_start_delay"`
+}
+
+// PlanEntry is one phase of a planned schedule.
+type PlanEntry struct {
+	Pomodoro int       `json:"pomodoro"`
+	Phase    string    `json:"phase"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+// buildPlan lays out count work sessions from start with the breaks the
+// server would take between them, each after the break start delay. No break
+// follows the last session.
+func buildPlan(phases PhaseDurations, count int, start time.Time) []PlanEntry {
+	var plan []PlanEntry
+	at := start
+	for i := 1; i <= count; i++ {
+		plan = append(plan, PlanEntry{Pomodoro: i, Phase: "work", Start: at, End: at.Add(phases.Work)})
+		at = at.Add(phases.Work)
+		if i == count {
+			break
+		}
+
+		phase, length := "short_break", phases.ShortBreak
+		if phases.LongBreakInterval > 0 && phases.LongBreak > 0 && i%phases.LongBreakInterval == 0 {
+			phase, length = "long_break", phases.LongBreak
+		}
+		if length > 0 {
+			at = at.Add(phases.BreakStartDelay)
+			plan = append(plan, PlanEntry{Pomodoro: i, Phase: phase, Start: at, End: at.Add(length)})
+			at = at.Add(length)
+		}
+	}
+	return plan
+}
+
+// parseInterspersed parses fs from args, allowing flags after positional
+// arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// plan prints a schedule for the given number of pomodoros starting now,
+// using the durations configured on the server.
+func plan(args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the schedule as JSON")
+	positional := parseInterspersed(fs, args)
+	if len(positional) != 1 {
+		fail("Usage: pomidorasctl plan [--json] <pomodoros>")
+	}
+	count, err := strconv.Atoi(positional[0])
+	if err != nil || count <= 0 {
+		fail("Invalid number of pomodoros:", positional[0])
+	}
+
+	resp, err := sendRequest(Request{Type: RequestTypeGetConfig})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success || resp.Config == nil {
+		fail("Server error:", resp.Message)
+	}
+
+	schedule := buildPlan(*resp.Config, count, time.Now().Truncate(time.Minute))
+	if *asJSON || jsonOutput {
+		out, _ := json.MarshalIndent(schedule, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Printf("%3s  %-11s  %-5s  %s\n", "#", "Phase", "Start", "End")
+	for _, entry := range schedule {
+		number := ""
+		if entry.Phase == "work" {
+			number = strconv.Itoa(entry.Pomodoro)
+		}
+		fmt.Printf("%3s  %-11s  %s  %s\n", number, strings.ReplaceAll(entry.Phase, "_", " "),
+			entry.Start.Format("15:04"), entry.End.Format("15:04"))
+	}
+}
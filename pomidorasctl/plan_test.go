@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildPlan(t *testing.T) {
+	phases := PhaseDurations{Work: 25 * time.Minute, ShortBreak: 5 * time.Minute, LongBreak: 15 * time.Minute, LongBreakInterval: 2}
+	start := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+
+	plan := buildPlan(phases, 3, start)
+
+	want := []struct {
+		phase      string
+		start, end string
+	}{
+		{"work", "14:00", "14:25"},
+		{"short_break", "14:25", "14:30"},
+		{"work", "14:30", "14:55"},
+		{"long_break", "14:55", "15:10"},
+		{"work", "15:10", "15:35"},
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(plan), len(want), plan)
+	}
+	for i, w := range want {
+		got := plan[i]
+		if got.Phase != w.phase || got.Start.Format("15:04") != w.start || got.End.Format("15:04") != w.end {
+			t.Errorf("entry %d: got %s %s-%s, want %s %s-%s", i, got.Phase,
+				got.Start.Format("15:04"), got.End.Format("15:04"), w.phase, w.start, w.end)
+		}
+	}
+}
+
+func TestBuildPlanWithoutBreaks(t *testing.T) {
+	plan := buildPlan(PhaseDurations{Work: time.Hour}, 2, time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC))
+
+	if len(plan) != 2 || plan[1].Start.Hour() != 10 {
+		t.Errorf("got %+v, want two back to back work sessions", plan)
+	}
+}
+
+func TestBuildPlanBreakStartDelay(t *testing.T) {
+	phases := PhaseDurations{Work: 25 * time.Minute, ShortBreak: 5 * time.Minute, BreakStartDelay: 2 * time.Minute}
+	plan := buildPlan(phases, 2, time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC))
+
+	if len(plan) != 3 || plan[1].Start.Format("15:04") != "14:27" || plan[2].Start.Format("15:04") != "14:32" {
+		t.Errorf("got %+v, want the break and next session 2m later", plan)
+	}
+}
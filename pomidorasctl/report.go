@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// reportBarWidth is the length of the bar of the busiest day in a report.
+const reportBarWidth = 30
+
+type statsPayload struct {
+	Days int `json:"days"`
+}
+
+type reportResult struct {
+	Days     []DayTotal    `json:"days"`
+	Sessions int           `json:"sessions"`
+	Focused  time.Duration `json:"focused"`
+}
+
+// report prints the work completed on each of the last days, a week by
+// default, as a bar chart followed by the total. Days without any work
+// show up empty, and today counts so far.
+func report(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	week := fs.Bool("week", false, "report the last 7 days, the default")
+	days := fs.Int("days", 7, "report this many days ending today")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fail("Usage: pomidorasctl report [--week | --days <n>]")
+	}
+	if *week {
+		*days = 7
+	}
+	if *days < 1 {
+		fail("Invalid number of days.")
+	}
+
+	data, _ := json.Marshal(statsPayload{Days: *days})
+	resp, err := sendRequest(Request{Type: RequestTypeStats, Payload: string(data)})
+	if err != nil {
+		fail("Error querying server:", err)
+	}
+	if !resp.Success {
+		fail("Server error:", resp.Message)
+	}
+	result := reportResult{Days: resp.Stats}
+	if result.Days == nil {
+		result.Days = []DayTotal{}
+	}
+	for _, day := range result.Days {
+		result.Sessions += day.Sessions
+		result.Focused += day.Focused
+	}
+	if jsonOutput {
+		printJSON(result)
+		return
+	}
+	for _, line := range renderReport(result) {
+		fmt.Println(line)
+	}
+}
+
+// renderReport draws a line per day with a bar scaled to the busiest day,
+// then the total.
+func renderReport(result reportResult) []string {
+	var busiest time.Duration
+	for _, day := range result.Days {
+		busiest = max(busiest, day.Focused)
+	}
+	var lines []string
+	for _, day := range result.Days {
+		length := 0
+		if busiest > 0 {
+			length = int(math.Round(float64(day.Focused) / float64(busiest) * reportBarWidth))
+			if day.Focused > 0 {
+				length = max(length, 1) // Show that there was some work
+			}
+		}
+		bar := strings.Repeat(ringFilled, length) + strings.Repeat(" ", reportBarWidth-length)
+		lines = append(lines, fmt.Sprintf("%s  %s  %6s  %s",
+			day.Date.Local().Format("Mon 01-02"), bar, formatHoursMinutes(day.Focused), pluralSessions(day.Sessions)))
+	}
+	lines = append(lines, fmt.Sprintf("Total      %s  %6s  %s",
+		strings.Repeat(" ", reportBarWidth), formatHoursMinutes(result.Focused), pluralSessions(result.Sessions)))
+	return lines
+}
+
+func pluralSessions(n int) string {
+	if n == 1 {
+		return "1 session"
+	}
+	return fmt.Sprintf("%d sessions", n)
+}
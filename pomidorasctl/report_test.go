@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderReport(t *testing.T) {
+	day := func(date int, sessions int, focused time.Duration) DayTotal {
+		return DayTotal{Date: time.Date(2024, 3, date, 0, 0, 0, 0, time.Local), Sessions: sessions, Focused: focused}
+	}
+	result := reportResult{
+		Days:     []DayTotal{day(8, 4, 100*time.Minute), day(9, 0, 0), day(10, 1, time.Minute)},
+		Sessions: 5,
+		Focused:  101 * time.Minute,
+	}
+	lines := renderReport(result)
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want a line per day and the total:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+
+	bar := func(line string) int { return strings.Count(line, ringFilled) }
+	for i, want := range []struct {
+		prefix string
+		bar    int
+		suffix string
+	}{
+		{"Fri 03-08", reportBarWidth, "1h40m  4 sessions"},
+		{"Sat 03-09", 0, "0m  0 sessions"}, // Days without work still show
+		{"Sun 03-10", 1, "1m  1 session"},  // Any work gets some bar
+		{"Total", 0, "1h41m  5 sessions"},
+	} {
+		line := lines[i]
+		if !strings.HasPrefix(line, want.prefix) || !strings.HasSuffix(line, want.suffix) || bar(line) != want.bar {
+			t.Errorf("line %d is %q, want %s with a bar of %d ending %q", i, line, want.prefix, want.bar, want.suffix)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	ringFilled = "█"
+	ringEmpty  = "░"
+)
+
+// progress is the fraction of the current phase already counted down, from 0
+// to 1. It is 0 without a phase length, such as when idle.
+func progress(status TimerStatus) float64 {
+	if status.InitialDuration <= 0 {
+		return 0
+	}
+	done := 1 - float64(status.Duration)/float64(status.InitialDuration)
+	return math.Min(math.Max(done, 0), 1)
+}
+
+// drawRing draws a ring of the given radius in rows, filled clockwise from
+// the top for the fraction done, with label centered inside or, if it does
+// not fit, underneath. Columns count double since terminal cells are about
+// twice as tall as they are wide, so the ring is 4*radius+1 columns wide.
+func drawRing(done float64, radius int, label string) []string {
+	width := 4*radius + 1
+	var lines []string
+	for y := -radius; y <= radius; y++ {
+		var line strings.Builder
+		for x := -2 * radius; x <= 2*radius; x++ {
+			dx := float64(x) / 2
+			dist := math.Hypot(dx, float64(y))
+			if dist < float64(radius)-1 || dist > float64(radius)+0.5 {
+				line.WriteByte(' ')
+				continue
+			}
+			// Angle clockwise from 12 o'clock, as a fraction of a turn.
+			angle := math.Atan2(dx, float64(-y)) / (2 * math.Pi)
+			if angle < 0 {
+				angle++
+			}
+			if angle < done {
+				line.WriteString(ringFilled)
+			} else {
+				line.WriteString(ringEmpty)
+			}
+		}
+		text := line.String()
+		if y == 0 && len(label) <= 4*radius-5 {
+			// Overwrite the hole in the middle, which is all spaces.
+			runes := []rune(text)
+			copy(runes[(width-len(label))/2:], []rune(label))
+			text = string(runes)
+		}
+		lines = append(lines, strings.TrimRight(text, " "))
+	}
+	if len(label) > 4*radius-5 {
+		lines = append(lines, strings.Repeat(" ", max((width-len(label))/2, 0))+label)
+	}
+	return lines
+}
+
+// drawBar draws a linear progress bar width cells wide for the fraction done.
+func drawBar(done float64, width int) string {
+	filled := int(math.Round(done * float64(width)))
+	return strings.Repeat(ringFilled, filled) + strings.Repeat(ringEmpty, width-filled)
+}
+
+type ringResult struct {
+	Remaining time.Duration `json:"remaining"`
+	Progress  float64       `json:"progress"`
+}
+
+// ring shows the progress of the current phase as a ring, redrawn in place
+// whenever the status changes. Terminals too narrow for the ring get a bar.
+func ring(args []string) {
+	fs := flag.NewFlagSet("ring", flag.ExitOnError)
+	size := fs.Int("size", 5, "radius of the ring in rows, at least 2")
+	fs.Parse(args)
+	if *size < 2 {
+		fail("Usage: pomidorasctl ring [--size <rows>]")
+	}
+
+	tty := stdoutIsTerminal()
+	drawn := 0 // Lines of the previous frame, to redraw over
+	var last *statusKey
+	for {
+		resp, err := sendRequest(Request{Type: RequestTypeLongPollStatus})
+		if err != nil {
+			fail("Error querying server:", err)
+		}
+		if !resp.Success {
+			fail("Server error:", resp.Message)
+		}
+		status := resp.Status
+		key := keyOf(status)
+		if last != nil && key == *last {
+			continue
+		}
+		last = &key
+
+		done := progress(status)
+		if jsonOutput {
+			printJSON(ringResult{Remaining: status.Duration, Progress: done})
+			continue
+		}
+		label := fmt.Sprintf("%s %d%%", formatRemaining(status.Duration), int(done*100))
+		if status.State != StateCountdown && status.State != StatePaused {
+			label = "Idle"
+		}
+
+		width := 80
+		if tty {
+			if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+				width = w
+			}
+		}
+		var lines []string
+		if width >= 4**size+1 {
+			lines = drawRing(done, *size, label)
+		} else {
+			lines = []string{drawBar(done, max(width-len(label)-1, 1)) + " " + label}
+		}
+
+		if tty && drawn > 0 {
+			fmt.Printf("\x1b[%dA", drawn) // Back to the top of the previous frame
+		}
+		for _, line := range lines {
+			if tty {
+				line += "\x1b[K" // Clear what the previous frame left on the line
+			}
+			fmt.Println(line)
+		}
+		drawn = len(lines)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		status TimerStatus
+		want   float64
+	}{
+		{TimerStatus{State: StateIdle}, 0},
+		{TimerStatus{State: StateCountdown, Duration: 15 * time.Minute, InitialDuration: 20 * time.Minute}, 0.25},
+		{TimerStatus{State: StateCountdown, Duration: 0, InitialDuration: 20 * time.Minute}, 1},
+		{TimerStatus{State: StateCountdown, Duration: 30 * time.Minute, InitialDuration: 20 * time.Minute}, 0},
+	}
+	for _, tt := range tests {
+		if got := progress(tt.status); got != tt.want {
+			t.Errorf("progress(%+v) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestDrawRing(t *testing.T) {
+	empty := drawRing(0, 5, "25:00 0%")
+	if len(empty) != 11 {
+		t.Fatalf("got %d lines, want 11 for radius 5", len(empty))
+	}
+	if text := strings.Join(empty, "\n"); strings.Contains(text, ringFilled) || !strings.Contains(empty[5], "25:00 0%") {
+		t.Errorf("empty ring:\n%s", text)
+	}
+	if text := strings.Join(drawRing(1, 5, ""), "\n"); strings.Contains(text, ringEmpty) {
+		t.Errorf("full ring:\n%s", text)
+	}
+
+	// A quarter done fills the top right: the right end of the middle row,
+	// but not the left end or the bottom.
+	quarter := drawRing(0.25, 5, "")
+	if !strings.HasSuffix(quarter[4], ringFilled) || strings.HasSuffix(quarter[6], ringFilled) {
+		t.Errorf("quarter ring:\n%s", strings.Join(quarter, "\n"))
+	}
+	if strings.Contains(quarter[10], ringFilled) {
+		t.Errorf("quarter ring fills the bottom:\n%s", strings.Join(quarter, "\n"))
+	}
+
+	// Labels too wide for the hole go underneath.
+	small := drawRing(0.5, 2, "25:00 50%")
+	if len(small) != 6 || !strings.Contains(small[5], "25:00 50%") {
+		t.Errorf("small ring:\n%s", strings.Join(small, "\n"))
+	}
+}
+
+func TestDrawBar(t *testing.T) {
+	if got, want := drawBar(0.3, 10), strings.Repeat(ringFilled, 3)+strings.Repeat(ringEmpty, 7); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Protocol versions a connection can switch between with RequestTypeProtocol.
+const (
+	ProtocolJSON   = "1" // Newline-delimited JSON, the default
+	ProtocolFramed = "2" // Each message is a 4-byte big-endian length followed by JSON
+)
+
+// maxFrameSize bounds a length-prefixed request so a bad header cannot make
+// the server allocate arbitrary amounts of memory.
+const maxFrameSize = 1 << 20
+
+// errMalformedFrame is returned for a complete frame whose body is not a
+// valid request. The framing itself is intact, so the connection can go on.
+var errMalformedFrame = errors.New("malformed request frame")
+
+// codec reads requests from and writes responses to a connection.
+type codec interface {
+	ReadRequest(req *Request) error
+	WriteResponse(resp Response) error
+}
+
+type jsonCodec struct {
+	decoder *json.Decoder
+	encoder *json.Encoder
+}
+
+func newJSONCodec(rw io.ReadWriter) *jsonCodec {
+	return &jsonCodec{decoder: json.NewDecoder(rw), encoder: json.NewEncoder(rw)}
+}
+
+func (c *jsonCodec) ReadRequest(req *Request) error {
+	return c.decoder.Decode(req)
+}
+
+func (c *jsonCodec) WriteResponse(resp Response) error {
+	return c.encoder.Encode(resp)
+}
+
+// framed switches to length-prefixed framing, keeping any bytes the JSON
+// decoder already read past the last request. The newline ending that request
+// is dropped; a frame header never starts with whitespace since its first
+// byte is zero for any size up to maxFrameSize.
+func (c *jsonCodec) framed(rw io.ReadWriter) *framedCodec {
+	buffered, _ := io.ReadAll(c.decoder.Buffered())
+	buffered = bytes.TrimLeft(buffered, " \t\r\n")
+	return &framedCodec{r: io.MultiReader(bytes.NewReader(buffered), rw), w: rw}
+}
+
+type framedCodec struct {
+	r io.Reader
+	w io.Writer
+}
+
+func (c *framedCodec) ReadRequest(req *Request) error {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return fmt.Errorf("request frame of %d bytes exceeds %d", size, maxFrameSize)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, req); err != nil {
+		return errMalformedFrame
+	}
+	return nil
+}
+
+func (c *framedCodec) WriteResponse(resp Response) error {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+	_, err = c.w.Write(append(frame, body...))
+	return err
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LogLine is one line of server output. Seq increases by one per line and
+// never repeats while the server runs.
+type LogLine struct {
+	Seq  int64  `json:"seq"`
+	Text string `json:"text"`
+}
+
+// LogBuffer keeps the most recent lines written to it, to serve
+// RequestTypeLogs. A nil *LogBuffer keeps nothing.
+type LogBuffer struct {
+	mu      sync.Mutex
+	size    int
+	lines   []LogLine
+	nextSeq int64
+	partial []byte        // Written text not yet ended by a newline
+	changed chan struct{} // Closed and replaced whenever a line is added
+}
+
+// NewLogBuffer returns a LogBuffer keeping the last size lines.
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{size: size, nextSeq: 1, changed: make(chan struct{})}
+}
+
+// Write splits p into lines, keeping any unfinished last line for the next
+// call. It never fails.
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.partial = append(b.partial, p...)
+	added := false
+	for {
+		i := bytes.IndexByte(b.partial, '\n')
+		if i < 0 {
+			break
+		}
+		b.lines = append(b.lines, LogLine{Seq: b.nextSeq, Text: string(b.partial[:i])})
+		b.nextSeq++
+		b.partial = b.partial[i+1:]
+		added = true
+	}
+	if len(b.lines) > b.size {
+		b.lines = append([]LogLine(nil), b.lines[len(b.lines)-b.size:]...)
+	}
+	if added {
+		close(b.changed)
+		b.changed = make(chan struct{})
+	}
+	return len(p), nil
+}
+
+// Since returns the buffered lines after seq, oldest first, and a channel
+// that is closed when another line is added.
+func (b *LogBuffer) Since(seq int64) ([]LogLine, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines []LogLine
+	for _, line := range b.lines {
+		if line.Seq > seq {
+			lines = append(lines, line)
+		}
+	}
+	return lines, b.changed
+}
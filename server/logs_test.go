@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sakalys/pomidoras/timer"
+)
+
+func TestLogBuffer(t *testing.T) {
+	b := NewLogBuffer(2)
+	fmt.Fprint(b, "one\ntw")
+	if lines, _ := b.Since(0); len(lines) != 1 || lines[0].Text != "one" {
+		t.Fatalf("got %+v, want only the finished line", lines)
+	}
+
+	fmt.Fprint(b, "o\nthree\n")
+	lines, _ := b.Since(0)
+	if len(lines) != 2 || lines[0] != (LogLine{Seq: 2, Text: "two"}) || lines[1] != (LogLine{Seq: 3, Text: "three"}) {
+		t.Errorf("got %+v, want the last two lines", lines)
+	}
+	if lines, _ := b.Since(2); len(lines) != 1 || lines[0].Seq != 3 {
+		t.Errorf("since 2: got %+v, want line 3", lines)
+	}
+}
+
+func TestLogsFollow(t *testing.T) {
+	logs := NewLogBuffer(10)
+	s := New(timer.New(0), WithLogs(logs))
+	fmt.Fprintln(logs, "Server listening")
+
+	resp := send(t, s, Request{Type: RequestTypeLogs})
+	if !resp.Success || len(resp.Logs) != 1 || resp.Logs[0].Text != "Server listening" {
+		t.Fatalf("got %+v, want the buffered line", resp)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintln(logs, "Error sending notification")
+	}()
+	resp = send(t, s, Request{Type: RequestTypeLogs, Payload: "1"})
+	if !resp.Success || len(resp.Logs) != 1 || resp.Logs[0].Text != "Error sending notification" {
+		t.Errorf("got %+v, want the line written while waiting", resp)
+	}
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerUID returns the user on the other end of a Unix domain socket, from
+// SO_PEERCRED. ok is false for other connections.
+func peerUID(conn net.Conn) (uid int, ok bool) {
+	unixConn, isUnix := conn.(*net.UnixConn)
+	if !isUnix {
+		return 0, false
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil || credErr != nil {
+		return 0, false
+	}
+	return int(cred.Uid), true
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket allowing up to perMinute operations a minute,
+// refilled continuously. A nil *rateLimiter allows everything.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	tokens    float64
+	last      time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, tokens: float64(perMinute), last: time.Now()}
+}
+
+// Allow takes a token from the bucket, reporting false if it is empty.
+func (r *rateLimiter) Allow() bool {
+	if r == nil {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Minutes() * float64(r.perMinute)
+	if r.tokens > float64(r.perMinute) {
+		r.tokens = float64(r.perMinute)
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
@@ -0,0 +1,1071 @@
+// Package server implements the pomidoras control protocol on top of a
+// timer.Timer. A Server answers pomidorasctl requests on any connection,
+// typically accepted from the Unix domain socket at DefaultSocketPath:
+//
+//	s := server.New(t, server.WithNudge(2*time.Minute))
+//	for {
+//		conn, err := listener.Accept()
+//		...
+//		go s.HandleConnection(conn)
+//	}
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sakalys/pomidoras/timer"
+)
+
+// DefaultSocketPath resolves the socket location: $XDG_RUNTIME_DIR/pomidoras.sock
+// when XDG_RUNTIME_DIR is set, so each user's server gets a private socket,
+// and /tmp/pomidoras.sock otherwise. pomidorasctl resolves it the same way.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "pomidoras.sock")
+	}
+	return "/tmp/pomidoras.sock"
+}
+
+// Server answers requests about a single timer.
+type Server struct {
+	timer          *timer.Timer
+	started        time.Time
+	nudgeAmount    time.Duration
+	lockDuringWork bool
+	logs           *LogBuffer    // nil when output is not being kept
+	addLimiter     *rateLimiter  // nil means unlimited
+	token          string        // Required by HandleRemoteConnection
+	heartbeat      time.Duration // See WithHeartbeat
+	connSlots      chan struct{} // One per connection being served; nil means unlimited
+}
+
+// DefaultHeartbeat is how long a subscription stays quiet before a
+// heartbeat is sent, see WithHeartbeat.
+const DefaultHeartbeat = 15 * time.Second
+
+// Option configures a Server, see New.
+type Option func(*Server)
+
+// WithNudge sets how much time RequestTypeNudge adds, a minute by default.
+func WithNudge(d time.Duration) Option {
+	return func(s *Server) { s.nudgeAmount = d }
+}
+
+// WithFocusLock refuses resets while a work session is counting down unless
+// the request is forced.
+func WithFocusLock(lock bool) Option {
+	return func(s *Server) { s.lockDuringWork = lock }
+}
+
+// WithAddLimit caps add and nudge requests at perMinute operations a minute.
+// Zero, the default, means unlimited.
+func WithAddLimit(perMinute int) Option {
+	return func(s *Server) {
+		if perMinute > 0 {
+			s.addLimiter = newRateLimiter(perMinute)
+		} else {
+			s.addLimiter = nil
+		}
+	}
+}
+
+// WithLogs serves RequestTypeLogs from b. Without it, log requests fail.
+func WithLogs(b *LogBuffer) Option {
+	return func(s *Server) { s.logs = b }
+}
+
+// WithToken sets the shared token that requests on connections served with
+// HandleRemoteConnection must carry in Request.Token.
+func WithToken(token string) Option {
+	return func(s *Server) { s.token = token }
+}
+
+// WithHeartbeat sends subscribers a Response with Heartbeat set whenever
+// their stream has been quiet for interval, as it is while the timer is
+// idle, so that proxies keep the connection open and clients can tell a
+// dead server from a quiet one. Zero turns heartbeats off. Defaults to
+// DefaultHeartbeat.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(s *Server) { s.heartbeat = interval }
+}
+
+// WithMaxConnections serves at most n connections at once. Further clients
+// get ErrorCodeBusy in reply to their first request and are disconnected,
+// rather than queueing behind long-poll or subscribe connections. A long
+// poll keeps its slot until it returns, up to maxLongPoll, even if its
+// client has gone. Zero, the default, means unlimited.
+func WithMaxConnections(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.connSlots = make(chan struct{}, n)
+		} else {
+			s.connSlots = nil
+		}
+	}
+}
+
+// New returns a Server controlling t.
+func New(t *timer.Timer, opts ...Option) *Server {
+	s := &Server{timer: t, started: time.Now(), nudgeAmount: time.Minute, heartbeat: DefaultHeartbeat}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Request types for client-server communication
+type RequestType string
+
+const (
+	RequestTypeStatus     RequestType = "status"
+	RequestTypeAddSeconds RequestType = "add_seconds"
+
+	// RequestTypeReset restarts a work session of the initial duration, see
+	// timer.Timer.Reset. A duration in the payload becomes the new initial
+	// duration first, see timer.Timer.ResetInitial; zero leaves the timer
+	// idle and negative durations are refused. It replies with the new
+	// status and obeys the focus lock.
+	RequestTypeReset RequestType = "reset"
+
+	RequestTypeClearHistory RequestType = "clear_history"
+	RequestTypeNudge        RequestType = "nudge"
+	RequestTypeLongBreakIn  RequestType = "long_break_in"
+
+	// RequestTypeNextReminder reports the next reminder of the running work
+	// session in Response.Reminder, which is left out if none is pending.
+	RequestTypeNextReminder RequestType = "next_reminder"
+
+	// RequestTypeLongPollStatus waits for the next status change, or at most
+	// the duration in the payload (default and cap maxLongPoll), then
+	// replies like RequestTypeStatus.
+	RequestTypeLongPollStatus RequestType = "long_poll_status"
+
+	// RequestTypeProtocol switches the connection's framing to the protocol
+	// version in the payload, see ProtocolJSON and ProtocolFramed.
+	RequestTypeProtocol RequestType = "protocol"
+
+	// RequestTypeGetRemaining is the lightest status poll, see Response.Remaining.
+	RequestTypeGetRemaining RequestType = "get_remaining"
+
+	// RequestTypeStatusSince replies like RequestTypeStatus unless the
+	// status still matches the StatusSincePayload the client last saw, in
+	// which case it sets Response.NotModified instead.
+	RequestTypeStatusSince RequestType = "status_since"
+
+	// RequestTypePauseAll and RequestTypeResumeAll apply to every timer the
+	// server manages and report how many changed. The payload of
+	// RequestTypePauseAll is an optional reason for the pause, at most
+	// maxPauseReason characters, reported as timer.Status.PauseReason.
+	RequestTypePauseAll  RequestType = "pause_all"
+	RequestTypeResumeAll RequestType = "resume_all"
+
+	// RequestTypeGetConfig returns the work/break cycle in Response.Config:
+	// the work, short break and long break lengths, the long break interval
+	// and the break start delay, see timer.Timer.PhaseDurations. Clients
+	// rendering or estimating the cycle, like pomidorasctl plan, read it
+	// here rather than assuming the defaults.
+	RequestTypeGetConfig RequestType = "get_config"
+
+	// RequestTypeStart begins a work session after the delay in the payload
+	// (a duration, default right away), see timer.Timer.ScheduleStart.
+	RequestTypeStart RequestType = "start"
+
+	// RequestTypeHistory returns every history record, see Response.History.
+	RequestTypeHistory RequestType = "history"
+
+	// RequestTypeStreak reports the current and longest runs of days with a
+	// completed work session, see timer.Streaks and Response.Streak.
+	RequestTypeStreak RequestType = "streak"
+
+	// RequestTypeGoal reports today's progress towards the daily goal, see
+	// timer.WithDailyGoal and Response.Goal.
+	RequestTypeGoal RequestType = "goal"
+
+	// RequestTypeStats returns the work completed on each recent day, see
+	// StatsPayload and Response.Stats.
+	RequestTypeStats RequestType = "stats"
+
+	// RequestTypeLogs returns the server's recent output, see Response.Logs.
+	// With a sequence number in the payload it returns only later lines,
+	// waiting up to maxLongPoll for one if there are none yet.
+	RequestTypeLogs RequestType = "logs"
+
+	// RequestTypePing is answered with "pong" and nothing else, to check
+	// that the server is up.
+	RequestTypePing RequestType = "ping"
+
+	// RequestTypeInfo reports the server version and uptime, see
+	// Response.Info.
+	RequestTypeInfo RequestType = "info"
+
+	// RequestTypeCapabilities describes what this server supports, see
+	// Capabilities.
+	RequestTypeCapabilities RequestType = "capabilities"
+
+	// RequestTypeAddPercent adds the percentage of the initial duration in
+	// the payload, see timer.Timer.AddPercent.
+	RequestTypeAddPercent RequestType = "add_percent"
+
+	// RequestTypeSubscribe turns the connection into a stream of timer
+	// events. After the reply, every event arrives as a Response with Event
+	// set until the client closes the connection, which takes no further
+	// requests. Responses with Heartbeat set instead only show the stream
+	// is alive, see WithHeartbeat.
+	RequestTypeSubscribe RequestType = "subscribe"
+
+	// RequestTypeConfigure changes the work/break cycle at runtime, see
+	// ConfigurePayload. It replies with the new cycle in Response.Config.
+	RequestTypeConfigure RequestType = "configure"
+
+	// RequestTypeSetInitial changes the length of future work sessions to
+	// the duration in the payload, see timer.Timer.SetInitialDuration. It
+	// replies with the new cycle in Response.Config.
+	RequestTypeSetInitial RequestType = "set_initial"
+
+	// RequestTypeImportHistory appends the JSON array of timer.HistoryRecord
+	// in the payload to the history, see timer.Timer.ImportHistory. The
+	// whole request is refused if any record is invalid, see
+	// validateImport.
+	RequestTypeImportHistory RequestType = "import_history"
+
+	// RequestTypeWhoami reports the server's view of the connection in
+	// Response.Whoami, to debug socket permissions and tokens.
+	RequestTypeWhoami RequestType = "whoami"
+
+	// RequestTypeSetDuration replaces whatever is running with a fresh work
+	// session of the duration in the payload, or if it is empty of the
+	// configured work length or a RequestTypeNextWork override, see
+	// timer.Timer.ResetTo. It replies with the new status and, like
+	// RequestTypeReset, obeys the focus lock.
+	RequestTypeSetDuration RequestType = "set_duration"
+
+	// RequestTypeNextWork makes only the next work session last the
+	// duration in the payload, after which the configured length applies
+	// again, see timer.Timer.SetNextWork. "0s" drops the override.
+	RequestTypeNextWork RequestType = "next_work"
+
+	// RequestTypeToggleMute mutes notifications and sounds, or unmutes them
+	// if they were muted, see timer.Timer.ToggleMute, and replies with the
+	// new state in Response.Muted.
+	RequestTypeToggleMute RequestType = "toggle_mute"
+)
+
+// ConfigurePayload is the JSON payload of RequestTypeConfigure. Omitted
+// fields keep their current values. Durations use time.ParseDuration syntax.
+// Changes apply from the next phase unless ApplyNow is set.
+type ConfigurePayload struct {
+	Work              string `json:"work,omitempty"`
+	ShortBreak        string `json:"short_break,omitempty"`
+	LongBreak         string `json:"long_break,omitempty"`
+	LongBreakInterval *int   `json:"long_break_interval,omitempty"`
+	BreakStartDelay   string `json:"break_start_delay,omitempty"`
+	ApplyNow          bool   `json:"apply_now,omitempty"`
+}
+
+// validateImport checks the records of RequestTypeImportHistory: each needs
+// a timestamp no later than now, a known phase or none, and a positive
+// duration.
+func validateImport(records []timer.HistoryRecord, now time.Time) error {
+	for i, record := range records {
+		switch {
+		case record.Timestamp.IsZero():
+			return fmt.Errorf("record %d has no timestamp", i+1)
+		case record.Timestamp.After(now):
+			return fmt.Errorf("record %d is in the future", i+1)
+		case record.Duration <= 0:
+			return fmt.Errorf("record %d has no duration", i+1)
+		}
+		switch record.Phase {
+		case "", timer.PhaseWork, timer.PhaseShortBreak, timer.PhaseLongBreak:
+		default:
+			return fmt.Errorf("record %d has unknown phase %q", i+1, record.Phase)
+		}
+	}
+	return nil
+}
+
+// StatusSincePayload is the JSON payload of RequestTypeStatusSince, the
+// status the client last saw. It is unchanged when the state, phase and
+// flash are equal and the remaining duration has the same whole number of
+// seconds, truncated like Response.Remaining.
+type StatusSincePayload struct {
+	State     timer.State `json:"state"`
+	Phase     timer.Phase `json:"phase,omitempty"`
+	Remaining int         `json:"remaining"`
+	Flash     bool        `json:"flash,omitempty"`
+}
+
+// StatsPayload is the optional JSON payload of RequestTypeStats. Days is
+// how many days to total, ending today, from 1 to MaxStatsDays; an empty
+// payload means DefaultStatsDays.
+type StatsPayload struct {
+	Days int `json:"days"`
+}
+
+const (
+	DefaultStatsDays = 7
+	MaxStatsDays     = 366
+)
+
+// maxPauseReason is the longest reason RequestTypePauseAll accepts, short
+// enough for a status bar.
+const maxPauseReason = 64
+
+// stateCodes are the one-letter states of RequestTypeGetRemaining, each
+// unique. Most are the first letter of the state.
+var stateCodes = map[timer.State]string{
+	timer.StateCountdown:     "c",
+	timer.StateIdle:          "i",
+	timer.StatePaused:        "p",
+	timer.StateScheduled:     "s",
+	timer.StateStartingBreak: "b",
+}
+
+// stateCode returns the code of state in stateCodes, or the whole state
+// for one without a code.
+func stateCode(state timer.State) string {
+	if code, ok := stateCodes[state]; ok {
+		return code
+	}
+	return string(state)
+}
+
+// timerChanged reports whether the timer moved from before to after: a new
+// state, phase, remaining time, start or pause reason, or a new cycle.
+func timerChanged(before, after timer.Status, phasesBefore, phasesAfter timer.PhaseDurations) bool {
+	return before.State != after.State || before.Phase != after.Phase || before.Duration != after.Duration ||
+		!before.StartedAt.Equal(after.StartedAt) || before.PauseReason != after.PauseReason || phasesBefore != phasesAfter
+}
+
+// unchanged reports whether status still matches p.
+func (p StatusSincePayload) unchanged(status timer.Status) bool {
+	return status.State == p.State && status.Phase == p.Phase && int(status.Duration.Seconds()) == p.Remaining && status.Flash == p.Flash
+}
+
+// requestTypes lists every request type handleRequest understands, as
+// reported by RequestTypeCapabilities.
+var requestTypes = []RequestType{
+	RequestTypeStatus,
+	RequestTypeAddSeconds,
+	RequestTypeReset,
+	RequestTypeClearHistory,
+	RequestTypeNudge,
+	RequestTypeLongBreakIn,
+	RequestTypeNextReminder,
+	RequestTypeLongPollStatus,
+	RequestTypeProtocol,
+	RequestTypeGetRemaining,
+	RequestTypeStatusSince,
+	RequestTypePauseAll,
+	RequestTypeResumeAll,
+	RequestTypeGetConfig,
+	RequestTypeStart,
+	RequestTypeHistory,
+	RequestTypeStreak,
+	RequestTypeGoal,
+	RequestTypeStats,
+	RequestTypeLogs,
+	RequestTypePing,
+	RequestTypeInfo,
+	RequestTypeCapabilities,
+	RequestTypeAddPercent,
+	RequestTypeConfigure,
+	RequestTypeSetInitial,
+	RequestTypeImportHistory,
+	RequestTypeWhoami,
+	RequestTypeSetDuration,
+	RequestTypeNextWork,
+	RequestTypeToggleMute,
+	RequestTypeSubscribe,
+}
+
+// Info describes the running server, see RequestTypeInfo.
+type Info struct {
+	Version   string        `json:"version"`
+	StartedAt time.Time     `json:"started_at"`
+	Uptime    time.Duration `json:"uptime"`
+}
+
+// Whoami answers RequestTypeWhoami.
+type Whoami struct {
+	UID        *int   `json:"uid,omitempty"` // The peer's user from SO_PEERCRED; left out over TCP
+	ServerUID  int    `json:"server_uid"`
+	Remote     bool   `json:"remote"`     // Every request must carry the token, see HandleRemoteConnection
+	Authorized bool   `json:"authorized"` // The request carried the token set with WithToken
+	Protocol   string `json:"protocol"`   // ProtocolJSON or ProtocolFramed
+}
+
+// Version is reported by RequestTypeInfo. Release builds set it with
+// -ldflags "-X github.com/sakalys/pomidoras/server.Version=v1.2.0";
+// otherwise it is the module version from the build info.
+var Version = buildVersion()
+
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// Capabilities lets clients adapt to servers of other versions. Features
+// reports optional behavior by name, such as whether history is recorded.
+type Capabilities struct {
+	RequestTypes []RequestType   `json:"request_types"`
+	Features     map[string]bool `json:"features"`
+}
+
+const maxLongPoll = 60 * time.Second
+
+type Request struct {
+	Type    RequestType `json:"type"`
+	Payload string      `json:"payload,omitempty"` // Use string for flexibility
+	Force   bool        `json:"force,omitempty"`   // Override the focus lock
+	Token   string      `json:"token,omitempty"`   // See WithToken
+}
+
+type Response struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message,omitempty"`
+	Status  *timer.Status `json:"status,omitempty"`
+
+	// Remaining and State answer RequestTypeGetRemaining: whole seconds left
+	// and the one-letter code of the timer state in stateCodes, such as "c"
+	// (countdown) or "i" (idle).
+	Remaining *int   `json:"remaining,omitempty"`
+	State     string `json:"state,omitempty"`
+
+	// NotModified answers RequestTypeStatusSince when the status is as the
+	// client last saw it, and Status is left out.
+	NotModified bool `json:"not_modified,omitempty"`
+
+	// Heartbeat marks a subscription response that carries no event, see
+	// WithHeartbeat.
+	Heartbeat bool `json:"heartbeat,omitempty"`
+
+	// Muted answers RequestTypeToggleMute: whether notifications and
+	// sounds are muted now.
+	Muted *bool `json:"muted,omitempty"`
+
+	// Changed is set by every successful request that can change the
+	// timer, its configuration or its history, and reports whether this one
+	// did: resetting an idle timer to idle or pausing a paused one did not.
+	Changed *bool `json:"changed,omitempty"`
+
+	Error     *ResponseError           `json:"error,omitempty"` // Set when Success is false
+	LongBreak *timer.LongBreakEstimate `json:"long_break,omitempty"`
+	Reminder  *timer.ReminderEstimate  `json:"reminder,omitempty"`
+	Config    *timer.PhaseDurations    `json:"config,omitempty"`
+	History   []timer.HistoryRecord    `json:"history,omitempty"`
+	Streak    *timer.Streak            `json:"streak,omitempty"`
+	Goal      *timer.Goal              `json:"goal,omitempty"`
+	Stats     []timer.DayTotal         `json:"stats,omitempty"`
+	Logs      []LogLine                `json:"logs,omitempty"`
+
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	Info         *Info         `json:"info,omitempty"`
+	Whoami       *Whoami       `json:"whoami,omitempty"`
+
+	Event *timer.Event `json:"event,omitempty"` // See RequestTypeSubscribe
+}
+
+// ResponseError describes a failed request for programmatic clients. Code is
+// stable across versions, Detail is meant for humans and matches Message.
+type ResponseError struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// Error codes reported in ResponseError.Code.
+const (
+	ErrorCodeInvalidRequest = "invalid_request" // The request was not valid JSON
+	ErrorCodeUnknownType    = "unknown_type"    // The request type is not supported
+	ErrorCodeInvalidPayload = "invalid_payload" // The payload could not be parsed
+	ErrorCodeRateLimited    = "rate_limited"    // Too many add requests, see WithAddLimit
+	ErrorCodeFocusLocked    = "focus_locked"    // Refused during work, see WithFocusLock
+	ErrorCodeNotIdle        = "not_idle"        // The timer is already counting down or paused
+	ErrorCodeBelowMinimum   = "below_minimum"   // A subtraction was refused, see timer.WithMinRemaining
+	ErrorCodeUnauthorized   = "unauthorized"    // The token was missing or wrong, see WithToken
+	ErrorCodeNoHistory      = "no_history"      // The server does not record history
+	ErrorCodeInternal       = "internal"        // The server failed to carry out the request
+	ErrorCodeBusy           = "busy"            // Too many connections, see WithMaxConnections
+)
+
+// errorResponse builds a failed Response carrying both the legacy Message and
+// the structured Error.
+func errorResponse(code, message string) Response {
+	return Response{Success: false, Message: message, Error: &ResponseError{Code: code, Detail: message}}
+}
+
+// HandleConnection serves requests from conn in order until the client closes
+// it, so several commands can be batched over one connection. Connections
+// start with newline-delimited JSON and may switch framing with
+// RequestTypeProtocol.
+func (s *Server) HandleConnection(conn net.Conn) {
+	s.handle(conn, false)
+}
+
+// HandleRemoteConnection is HandleConnection for connections from other
+// machines, such as over TCP, where every request must carry the token set
+// with WithToken. Without a token every request is refused.
+func (s *Server) HandleRemoteConnection(conn net.Conn) {
+	s.handle(conn, true)
+}
+
+// authorized reports whether req carries the shared token.
+func (s *Server) authorized(req Request) bool {
+	return s.token != "" && subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.token)) == 1
+}
+
+func (s *Server) handle(conn net.Conn, requireToken bool) {
+	defer conn.Close()
+
+	jsonConn := newJSONCodec(conn)
+	var c codec = jsonConn
+	protocol := ProtocolJSON
+
+	if s.connSlots != nil {
+		select {
+		case s.connSlots <- struct{}{}:
+			defer func() { <-s.connSlots }()
+		default:
+			s.refuseBusy(conn, c)
+			return
+		}
+	}
+
+	for {
+		var req Request
+		if err := c.ReadRequest(&req); err != nil {
+			if err == io.EOF {
+				return
+			}
+			response := errorResponse(ErrorCodeInvalidRequest, "Invalid request format.")
+			if writeErr := c.WriteResponse(response); writeErr != nil {
+				logWriteError(writeErr)
+				return
+			}
+			if err == errMalformedFrame {
+				continue // The next frame is still readable
+			}
+			return
+		}
+
+		if requireToken && !s.authorized(req) {
+			c.WriteResponse(errorResponse(ErrorCodeUnauthorized, "Unauthorized."))
+			return
+		}
+
+		if req.Type == RequestTypeSubscribe {
+			// Subscribe before acknowledging so no event is missed.
+			events := s.timer.Events()
+			if err := c.WriteResponse(s.handleRequest(req)); err != nil {
+				logWriteError(err)
+				s.timer.Unsubscribe(events)
+				return
+			}
+			s.stream(conn, c, events)
+			return
+		}
+
+		response := s.handleRequest(req)
+		if whoami := response.Whoami; whoami != nil {
+			// Fill in what only the connection knows.
+			whoami.Remote = requireToken
+			whoami.Protocol = protocol
+			if uid, ok := peerUID(conn); ok {
+				whoami.UID = &uid
+			}
+		}
+		if req.Type == RequestTypeProtocol && response.Success {
+			// Acknowledge in the old framing, then switch.
+			if err := c.WriteResponse(response); err != nil {
+				logWriteError(err)
+				return
+			}
+			if req.Payload == ProtocolFramed {
+				c = jsonConn.framed(conn)
+				protocol = ProtocolFramed
+			}
+			continue
+		}
+		if err := c.WriteResponse(response); err != nil {
+			// Whatever the client missed, the rest of the connection
+			// cannot be trusted, so stop serving it.
+			logWriteError(err)
+			return
+		}
+	}
+}
+
+// logWriteError logs a failure to write a response, unless it is only the
+// client having gone away, which is no fault of the server.
+func logWriteError(err error) {
+	if errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error encoding response: %v\n", err)
+}
+
+// stream writes every event from events, a channel from s.timer.Events, to c
+// until the client closes conn or a write fails. Each subscriber has its own
+// buffered channel and writes from its own goroutine, so the timer never
+// waits on the network and a slow client only loses its own events.
+func (s *Server) stream(conn net.Conn, c codec, events <-chan timer.Event) {
+	defer s.timer.Unsubscribe(events)
+
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn) // Returns once the client closes the connection
+		close(closed)
+	}()
+	// Heartbeats only fill silences: every event restarts the ticker. A nil
+	// channel never fires, leaving them off.
+	var ticker *time.Ticker
+	var heartbeats <-chan time.Time
+	if s.heartbeat > 0 {
+		ticker = time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+	for {
+		var resp Response
+		select {
+		case event := <-events:
+			resp = Response{Success: true, Event: &event}
+			if ticker != nil {
+				ticker.Reset(s.heartbeat)
+			}
+		case <-heartbeats:
+			resp = Response{Success: true, Heartbeat: true}
+		case <-closed:
+			return
+		}
+		if err := c.WriteResponse(resp); err != nil {
+			logWriteError(err)
+			return
+		}
+	}
+}
+
+// busyReadTimeout bounds how long refuseBusy waits for the request it
+// answers.
+const busyReadTimeout = time.Second
+
+// refuseBusy answers the first request on a connection over the
+// WithMaxConnections limit with ErrorCodeBusy. Reading the request first
+// lets the client see the reply rather than a reset connection.
+func (s *Server) refuseBusy(conn net.Conn, c codec) {
+	conn.SetReadDeadline(time.Now().Add(busyReadTimeout))
+	var req Request
+	if err := c.ReadRequest(&req); err == io.EOF {
+		return
+	}
+	if err := c.WriteResponse(errorResponse(ErrorCodeBusy, "Server busy, try again later.")); err != nil {
+		logWriteError(err)
+	}
+}
+
+func (s *Server) handleRequest(req Request) Response {
+	t := s.timer
+	var response Response
+	switch req.Type {
+	case RequestTypeStatus:
+		status := t.Status()
+		response = Response{Success: true, Status: &status}
+	case RequestTypeGetRemaining:
+		status := t.Status()
+		remaining := int(status.Duration.Seconds())
+		response = Response{Success: true, Remaining: &remaining, State: stateCode(status.State)}
+	case RequestTypeStatusSince:
+		var since StatusSincePayload
+		if err := json.Unmarshal([]byte(req.Payload), &since); err != nil {
+			response = errorResponse(ErrorCodeInvalidPayload, "Invalid last-known status.")
+			break
+		}
+		status := t.Status()
+		if since.unchanged(status) {
+			response = Response{Success: true, NotModified: true}
+		} else {
+			response = Response{Success: true, Status: &status}
+		}
+	case RequestTypeAddSeconds:
+		seconds, err := strconv.Atoi(req.Payload)
+		if err != nil {
+			response = errorResponse(ErrorCodeInvalidPayload, "Invalid seconds value.")
+		} else if !s.addLimiter.Allow() {
+			response = errorResponse(ErrorCodeRateLimited, "Rate limited, try again later.")
+		} else if err := t.Add(time.Duration(seconds) * time.Second); err != nil {
+			response = errorResponse(ErrorCodeBelowMinimum, fmt.Sprintf("Cannot subtract %d seconds: %v.", -seconds, err))
+		} else {
+			changed := seconds != 0
+			response = Response{Success: true, Message: fmt.Sprintf("Added %d seconds.", seconds), Changed: &changed}
+		}
+	case RequestTypeAddPercent:
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(req.Payload, "%"), 64)
+		if err != nil || math.IsNaN(percent) || math.IsInf(percent, 0) {
+			response = errorResponse(ErrorCodeInvalidPayload, "Invalid percentage.")
+		} else if !s.addLimiter.Allow() {
+			response = errorResponse(ErrorCodeRateLimited, "Rate limited, try again later.")
+		} else if added, err := t.AddPercent(percent); errors.Is(err, timer.ErrBelowMinimum) {
+			response = errorResponse(ErrorCodeBelowMinimum, fmt.Sprintf("Cannot add a percentage: %v.", err))
+		} else if err != nil {
+			response = errorResponse(ErrorCodeInvalidRequest, fmt.Sprintf("Cannot add a percentage: %v.", err))
+		} else {
+			changed := added != 0
+			response = Response{Success: true, Message: fmt.Sprintf("Added %d seconds.", int(added.Seconds())), Changed: &changed}
+		}
+	case RequestTypeReset: // Handle the reset request
+		var d time.Duration
+		if req.Payload != "" {
+			var err error
+			if d, err = time.ParseDuration(req.Payload); err != nil || d < 0 {
+				response = errorResponse(ErrorCodeInvalidPayload, "Invalid duration, want zero or more.")
+				break
+			}
+		}
+		if s.focusLocked(req) {
+			response = errorResponse(ErrorCodeFocusLocked, "Focus lock: work session in progress, use force to reset.")
+			break
+		}
+		before, phasesBefore := t.Status(), t.PhaseDurations()
+		message := "Timer reset."
+		switch {
+		case req.Payload == "":
+			t.Reset()
+		case d == 0:
+			t.ResetInitial(0)
+			message = "Timer reset to idle."
+		default:
+			t.ResetInitial(d)
+			message = fmt.Sprintf("Timer reset to %v.", d)
+		}
+		status := t.Status()
+		changed := timerChanged(before, status, phasesBefore, t.PhaseDurations())
+		response = Response{Success: true, Message: message, Status: &status, Changed: &changed}
+	case RequestTypeSetDuration:
+		var d time.Duration
+		if req.Payload != "" {
+			var err error
+			if d, err = time.ParseDuration(req.Payload); err != nil || d <= 0 {
+				response = errorResponse(ErrorCodeInvalidPayload, "Invalid duration.")
+				break
+			}
+		}
+		if s.focusLocked(req) {
+			response = errorResponse(ErrorCodeFocusLocked, "Focus lock: work session in progress, use force to replace it.")
+			break
+		}
+		before, phasesBefore := t.Status(), t.PhaseDurations()
+		if d > 0 {
+			t.ResetTo(d)
+		} else {
+			t.Reset() // Uses up a RequestTypeNextWork override
+		}
+		status := t.Status()
+		changed := timerChanged(before, status, phasesBefore, t.PhaseDurations())
+		response = Response{Success: true, Message: fmt.Sprintf("Started a fresh %v work session.", status.InitialDuration), Status: &status, Changed: &changed}
+	case RequestTypeNextWork:
+		d, err := time.ParseDuration(req.Payload)
+		if err != nil || d < 0 {
+			response = errorResponse(ErrorCodeInvalidPayload, "Invalid duration.")
+			break
+		}
+		changed := t.SetNextWork(d)
+		response = Response{Success: true, Message: fmt.Sprintf("The next work session will last %v.", d), Changed: &changed}
+		if d == 0 {
+			response.Message = "The next work session will last the usual length."
+		}
+	case RequestTypeToggleMute:
+		muted := t.ToggleMute()
+		changed := true
+		response = Response{Success: true, Message: "Notifications unmuted.", Muted: &muted, Changed: &changed}
+		if muted {
+			response.Message = "Notifications muted."
+		}
+	case RequestTypeClearHistory:
+		removed, changed, err := t.ClearHistory()
+		if err != nil {
+			response = errorResponse(ErrorCodeInternal, fmt.Sprintf("Error clearing history: %v", err))
+		} else {
+			response = Response{Success: true, Message: fmt.Sprintf("Removed %d history records.", removed), Changed: &changed}
+		}
+	case RequestTypeImportHistory:
+		var records []timer.HistoryRecord
+		if err := json.Unmarshal([]byte(req.Payload), &records); err != nil {
+			response = errorResponse(ErrorCodeInvalidPayload, "Invalid history records.")
+			break
+		}
+		if err := validateImport(records, time.Now()); err != nil {
+			response = errorResponse(ErrorCodeInvalidPayload, fmt.Sprintf("Invalid history records: %v.", err))
+			break
+		}
+		imported, err := t.ImportHistory(records)
+		switch {
+		case errors.Is(err, timer.ErrNoHistory):
+			response = errorResponse(ErrorCodeNoHistory, "History is not recorded.")
+		case err != nil:
+			response = errorResponse(ErrorCodeInternal, fmt.Sprintf("Error writing history after %d records: %v", imported, err))
+		default:
+			changed := imported > 0
+			response = Response{Success: true, Message: fmt.Sprintf("Imported %d history records.", imported), Changed: &changed}
+		}
+	case RequestTypeHistory:
+		records, err := t.HistoryRecords()
+		if err != nil {
+			response = errorResponse(ErrorCodeInternal, fmt.Sprintf("Error reading history: %v", err))
+		} else {
+			response = Response{Success: true, History: records}
+		}
+	case RequestTypeStreak:
+		records, err := t.HistoryRecords()
+		if err != nil {
+			response = errorResponse(ErrorCodeInternal, fmt.Sprintf("Error reading history: %v", err))
+		} else {
+			streak := timer.Streaks(records, time.Now())
+			response = Response{Success: true, Streak: &streak}
+		}
+	case RequestTypeStats:
+		payload := StatsPayload{Days: DefaultStatsDays}
+		if req.Payload != "" {
+			if err := json.Unmarshal([]byte(req.Payload), &payload); err != nil || payload.Days < 1 || payload.Days > MaxStatsDays {
+				response = errorResponse(ErrorCodeInvalidPayload, fmt.Sprintf("Invalid stats request, days must be 1 to %d.", MaxStatsDays))
+				break
+			}
+		}
+		stats, err := t.Stats(payload.Days)
+		if err != nil {
+			response = errorResponse(ErrorCodeInternal, fmt.Sprintf("Error reading history: %v", err))
+		} else {
+			response = Response{Success: true, Stats: stats}
+		}
+	case RequestTypeGoal:
+		goal, err := t.Goal()
+		if err != nil {
+			response = errorResponse(ErrorCodeInternal, fmt.Sprintf("Error reading history: %v", err))
+		} else {
+			response = Response{Success: true, Goal: &goal}
+		}
+	case RequestTypeLogs:
+		if s.logs == nil {
+			response = errorResponse(ErrorCodeInternal, "Server output is not being kept.")
+			break
+		}
+		var after int64
+		if req.Payload != "" {
+			seq, err := strconv.ParseInt(req.Payload, 10, 64)
+			if err != nil || seq < 0 {
+				response = errorResponse(ErrorCodeInvalidPayload, "Invalid log sequence number.")
+				break
+			}
+			after = seq
+		}
+		lines, changed := s.logs.Since(after)
+		if len(lines) == 0 && req.Payload != "" {
+			select {
+			case <-changed:
+			case <-time.After(maxLongPoll):
+			}
+			lines, _ = s.logs.Since(after)
+		}
+		response = Response{Success: true, Logs: lines}
+	case RequestTypeNudge:
+		if !s.addLimiter.Allow() {
+			response = errorResponse(ErrorCodeRateLimited, "Rate limited, try again later.")
+			break
+		}
+		seconds := int(s.nudgeAmount.Seconds())
+		t.AddSeconds(seconds)
+		changed := seconds != 0
+		response = Response{Success: true, Message: fmt.Sprintf("Added %d seconds.", seconds), Changed: &changed}
+
+	case RequestTypeLongPollStatus:
+		wait := maxLongPoll
+		if req.Payload != "" {
+			parsed, err := time.ParseDuration(req.Payload)
+			if err != nil || parsed < 0 {
+				response = errorResponse(ErrorCodeInvalidPayload, "Invalid wait duration.")
+				break
+			}
+			wait = min(parsed, maxLongPoll)
+		}
+		select {
+		case <-t.Changed():
+		case <-time.After(wait):
+		}
+		status := t.Status()
+		response = Response{Success: true, Status: &status}
+	case RequestTypePauseAll:
+		reason := strings.TrimSpace(req.Payload)
+		if utf8.RuneCountInString(reason) > maxPauseReason {
+			response = errorResponse(ErrorCodeInvalidPayload, fmt.Sprintf("Pause reason too long, at most %d characters.", maxPauseReason))
+			break
+		}
+		paused := 0
+		if t.PauseWithReason(reason) {
+			paused++
+		}
+		changed := paused > 0
+		response = Response{Success: true, Message: fmt.Sprintf("Paused %d timers.", paused), Changed: &changed}
+	case RequestTypeResumeAll:
+		resumed := 0
+		if t.Resume() {
+			resumed++
+		}
+		changed := resumed > 0
+		response = Response{Success: true, Message: fmt.Sprintf("Resumed %d timers.", resumed), Changed: &changed}
+	case RequestTypeProtocol:
+		switch req.Payload {
+		case ProtocolJSON, ProtocolFramed:
+			response = Response{Success: true, Message: fmt.Sprintf("Using protocol version %s.", req.Payload)}
+		default:
+			response = errorResponse(ErrorCodeInvalidPayload, "Unsupported protocol version.")
+		}
+	case RequestTypeStart:
+		var delay time.Duration
+		if req.Payload != "" {
+			parsed, err := time.ParseDuration(req.Payload)
+			if err != nil {
+				response = errorResponse(ErrorCodeInvalidPayload, "Invalid start delay.")
+				break
+			}
+			delay = parsed
+		}
+		if !t.ScheduleStart(delay) {
+			response = errorResponse(ErrorCodeNotIdle, "A session is already in progress.")
+			break
+		}
+		changed := true // Refused above if there was nothing to start
+		if delay > 0 {
+			response = Response{Success: true, Message: fmt.Sprintf("Starting in %s.", delay.Round(time.Second)), Changed: &changed}
+		} else {
+			response = Response{Success: true, Message: "Timer started.", Changed: &changed}
+		}
+	case RequestTypeConfigure:
+		var payload ConfigurePayload
+		if err := json.Unmarshal([]byte(req.Payload), &payload); err != nil {
+			response = errorResponse(ErrorCodeInvalidPayload, "Invalid configuration.")
+			break
+		}
+		phases, err := applyConfigure(t.PhaseDurations(), payload)
+		if err != nil {
+			response = errorResponse(ErrorCodeInvalidPayload, fmt.Sprintf("Invalid configuration: %v.", err))
+			break
+		}
+		changed := t.SetPhaseDurations(phases, payload.ApplyNow)
+		response = Response{Success: true, Message: "Configuration updated.", Config: &phases, Changed: &changed}
+	case RequestTypeSetInitial:
+		d, err := time.ParseDuration(req.Payload)
+		if err != nil || d <= 0 {
+			response = errorResponse(ErrorCodeInvalidPayload, "Invalid duration.")
+			break
+		}
+		phasesBefore := t.PhaseDurations()
+		t.SetInitialDuration(d)
+		phases := t.PhaseDurations()
+		changed := phases != phasesBefore
+		response = Response{Success: true, Message: fmt.Sprintf("Initial duration set to %v.", d), Config: &phases, Changed: &changed}
+	case RequestTypeWhoami:
+		// handle adds the details of the connection.
+		response = Response{Success: true, Whoami: &Whoami{
+			ServerUID:  os.Getuid(),
+			Authorized: s.authorized(req),
+			Protocol:   ProtocolJSON,
+		}}
+	case RequestTypeSubscribe:
+		// HandleConnection streams the events after this reply.
+		response = Response{Success: true, Message: "Subscribed."}
+	case RequestTypeGetConfig:
+		phases := t.PhaseDurations()
+		response = Response{Success: true, Config: &phases}
+	case RequestTypePing:
+		response = Response{Success: true, Message: "pong"}
+	case RequestTypeInfo:
+		response = Response{Success: true, Info: &Info{
+			Version:   Version,
+			StartedAt: s.started,
+			Uptime:    time.Since(s.started).Round(time.Second),
+		}}
+	case RequestTypeCapabilities:
+		phases := t.PhaseDurations()
+		response = Response{Success: true, Capabilities: &Capabilities{
+			RequestTypes: requestTypes,
+			Features: map[string]bool{
+				"phases":      phases.ShortBreak > 0 || phases.LongBreak > 0,
+				"long_breaks": phases.LongBreak > 0 && phases.LongBreakInterval > 0,
+				"history":     t.HasHistory(),
+				"logs":        s.logs != nil,
+				"rate_limit":  s.addLimiter != nil,
+				"focus_lock":  s.lockDuringWork,
+			},
+		}}
+	case RequestTypeLongBreakIn:
+		estimate := t.LongBreakIn()
+		response = Response{Success: true, LongBreak: &estimate}
+	case RequestTypeNextReminder:
+		if estimate, ok := t.NextReminder(); ok {
+			response = Response{Success: true, Reminder: &estimate}
+		} else {
+			response = Response{Success: true, Message: "none"}
+		}
+
+	default:
+		response = errorResponse(ErrorCodeUnknownType, "Unknown request type.")
+	}
+	return response
+}
+
+// applyConfigure returns phases with the values set in payload.
+func applyConfigure(phases timer.PhaseDurations, payload ConfigurePayload) (timer.PhaseDurations, error) {
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+		zero  bool // Whether zero is allowed, disabling the phase or delay
+	}{
+		{"work", payload.Work, &phases.Work, false},
+		{"short_break", payload.ShortBreak, &phases.ShortBreak, true},
+		{"long_break", payload.LongBreak, &phases.LongBreak, true},
+		{"break_start_delay", payload.BreakStartDelay, &phases.BreakStartDelay, true},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d < 0 || (d == 0 && !field.zero) {
+			return phases, fmt.Errorf("bad %s duration %q", field.name, field.value)
+		}
+		*field.dst = d
+	}
+	if payload.LongBreakInterval != nil {
+		if *payload.LongBreakInterval < 0 {
+			return phases, fmt.Errorf("bad long_break_interval %d", *payload.LongBreakInterval)
+		}
+		phases.LongBreakInterval = *payload.LongBreakInterval
+	}
+	return phases, nil
+}
+
+// focusLocked reports whether req must be refused because a work session is
+// running under the focus lock, see WithFocusLock.
+func (s *Server) focusLocked(req Request) bool {
+	if !s.lockDuringWork || req.Force {
+		return false
+	}
+	status := s.timer.Status()
+	return status.State == timer.StateCountdown && status.Phase == timer.PhaseWork
+}
@@ -0,0 +1,1272 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sakalys/pomidoras/timer"
+)
+
+// roundTrip writes raw to a connection served by s and decodes the single
+// response.
+func roundTrip(t *testing.T, s *Server, raw string) Response {
+	t.Helper()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go s.HandleConnection(server)
+
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.WriteString(client, raw); err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(client).Decode(&resp); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	return resp
+}
+
+func send(t *testing.T, s *Server, req Request) Response {
+	t.Helper()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return roundTrip(t, s, string(data)+"\n")
+}
+
+func TestStatusIdle(t *testing.T) {
+	resp := send(t, New(timer.New(0)), Request{Type: RequestTypeStatus})
+
+	if !resp.Success {
+		t.Fatalf("status failed: %q", resp.Message)
+	}
+	if resp.Status.State != timer.StateIdle || resp.Status.Duration != 0 {
+		t.Errorf("got %+v, want idle with no duration", resp.Status)
+	}
+}
+
+func TestStatusCountdown(t *testing.T) {
+	// Not started, so the remaining time does not move under the test.
+	resp := send(t, New(timer.New(10*time.Minute)), Request{Type: RequestTypeStatus})
+
+	if !resp.Success {
+		t.Fatalf("status failed: %q", resp.Message)
+	}
+	want := timer.Status{
+		State:           timer.StateCountdown,
+		Duration:        10 * time.Minute,
+		Phase:           timer.PhaseWork,
+		InitialDuration: 10 * time.Minute,
+		CycleRemaining:  10*time.Minute + timer.DefaultPhaseDurations.ShortBreak,
+	}
+	if *resp.Status != want {
+		t.Errorf("got %+v, want %+v", resp.Status, want)
+	}
+}
+
+func TestAddSeconds(t *testing.T) {
+	tm := timer.New(0)
+	resp := send(t, New(tm), Request{Type: RequestTypeAddSeconds, Payload: "30"})
+
+	if !resp.Success || resp.Message != "Added 30 seconds." {
+		t.Fatalf("got %+v, want success adding 30 seconds", resp)
+	}
+	status := tm.Status()
+	if status.State != timer.StateCountdown || status.Duration <= 0 || status.Duration > 30*time.Second {
+		t.Errorf("got %+v, want a countdown of at most 30s", status)
+	}
+}
+
+func TestAddSecondsInvalidPayload(t *testing.T) {
+	for _, payload := range []string{"", "abc", "1.5", "10s"} {
+		tm := timer.New(0)
+		resp := send(t, New(tm), Request{Type: RequestTypeAddSeconds, Payload: payload})
+
+		if resp.Success || resp.Message != "Invalid seconds value." {
+			t.Errorf("payload %q: got %+v, want invalid seconds error", payload, resp)
+		}
+		if status := tm.Status(); status.State != timer.StateIdle {
+			t.Errorf("payload %q: timer left in %+v", payload, status)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	tm.AddSeconds(-300)
+
+	resp := send(t, New(tm), Request{Type: RequestTypeReset})
+
+	if !resp.Success || resp.Message != "Timer reset." {
+		t.Fatalf("got %+v, want successful reset", resp)
+	}
+	status := tm.Status()
+	if status.State != timer.StateCountdown || status.Duration <= 9*time.Minute {
+		t.Errorf("got %+v, want a fresh 10m countdown", status)
+	}
+}
+
+func TestUnknownRequestType(t *testing.T) {
+	resp := send(t, New(timer.New(0)), Request{Type: "bogus"})
+
+	if resp.Success || resp.Message != "Unknown request type." {
+		t.Errorf("got %+v, want unknown request type error", resp)
+	}
+}
+
+func TestMalformedRequest(t *testing.T) {
+	resp := roundTrip(t, New(timer.New(0)), "{not json\n")
+
+	if resp.Success || resp.Message != "Invalid request format." {
+		t.Errorf("got %+v, want invalid request format error", resp)
+	}
+}
+
+func TestNudge(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	resp := send(t, New(tm), Request{Type: RequestTypeNudge})
+
+	if !resp.Success || resp.Message != "Added 60 seconds." {
+		t.Fatalf("got %+v, want a 60 second nudge", resp)
+	}
+	if status := tm.Status(); status.Duration != 11*time.Minute {
+		t.Errorf("got %v remaining, want 11m", status.Duration)
+	}
+}
+
+func TestBatchedRequests(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go New(tm).HandleConnection(server)
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+
+	encoder := json.NewEncoder(client)
+	decoder := json.NewDecoder(client)
+	var resp Response
+	for _, req := range []Request{{Type: RequestTypeReset}, {Type: RequestTypeAddSeconds, Payload: "-60"}, {Type: RequestTypeStatus}} {
+		if err := encoder.Encode(req); err != nil {
+			t.Fatalf("sending %s: %v", req.Type, err)
+		}
+		if err := decoder.Decode(&resp); err != nil {
+			t.Fatalf("reading %s response: %v", req.Type, err)
+		}
+		if !resp.Success {
+			t.Fatalf("%s failed: %+v", req.Type, resp)
+		}
+	}
+	if resp.Status.Duration > 9*time.Minute {
+		t.Errorf("got %v remaining after reset and -60s, want at most 9m", resp.Status.Duration)
+	}
+}
+
+func TestAddRateLimited(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	s := New(tm, WithAddLimit(3))
+	for i := 0; i < 3; i++ {
+		if resp := send(t, s, Request{Type: RequestTypeAddSeconds, Payload: "1"}); !resp.Success {
+			t.Fatalf("add %d: got %+v, want success within the limit", i+1, resp)
+		}
+	}
+
+	for _, req := range []Request{{Type: RequestTypeAddSeconds, Payload: "1"}, {Type: RequestTypeNudge}} {
+		resp := send(t, s, req)
+		if resp.Success || resp.Message != "Rate limited, try again later." {
+			t.Errorf("%s over the limit: got %+v, want rate limited", req.Type, resp)
+		}
+	}
+	if status := tm.Status(); status.Duration != 10*time.Minute+3*time.Second {
+		t.Errorf("got %v remaining, want only the allowed adds applied", status.Duration)
+	}
+}
+
+func TestResetFocusLocked(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	tm.AddSeconds(-300)
+	s := New(tm, WithFocusLock(true))
+
+	resp := send(t, s, Request{Type: RequestTypeReset})
+	if resp.Success {
+		t.Fatalf("got %+v, want reset refused during work", resp)
+	}
+	if status := tm.Status(); status.Duration != 5*time.Minute {
+		t.Errorf("got %v remaining, want the refused reset to leave 5m", status.Duration)
+	}
+
+	resp = send(t, s, Request{Type: RequestTypeReset, Force: true})
+	if !resp.Success {
+		t.Fatalf("got %+v, want forced reset to succeed", resp)
+	}
+	if status := tm.Status(); status.Duration <= 9*time.Minute {
+		t.Errorf("got %v remaining, want a fresh 10m countdown", status.Duration)
+	}
+}
+
+func TestSetDuration(t *testing.T) {
+	tm := timer.New(10*time.Minute, timer.WithPhases(timer.PhaseDurations{Work: 25 * time.Minute}))
+	tm.AddSeconds(-300)
+	s := New(tm, WithFocusLock(true))
+
+	if resp := send(t, s, Request{Type: RequestTypeSetDuration, Payload: "50m"}); resp.Success {
+		t.Fatalf("got %+v, want refused by the focus lock", resp)
+	}
+	resp := send(t, s, Request{Type: RequestTypeSetDuration, Payload: "50m", Force: true})
+	defer tm.Pause()
+	if !resp.Success || resp.Status == nil || resp.Status.Duration != 50*time.Minute || resp.Status.Phase != timer.PhaseWork {
+		t.Fatalf("got %+v, want a fresh 50m work session", resp)
+	}
+	if got := tm.PhaseDurations().Work; got != 10*time.Minute {
+		t.Errorf("work length changed to %v, want the initial 10m kept", got)
+	}
+
+	// Without a duration, the configured work length.
+	resp = send(t, s, Request{Type: RequestTypeSetDuration, Force: true})
+	if !resp.Success || resp.Status.Duration != 10*time.Minute {
+		t.Errorf("got %+v, want a fresh 10m work session", resp)
+	}
+
+	for _, payload := range []string{"0s", "-5m", "soon"} {
+		resp := send(t, s, Request{Type: RequestTypeSetDuration, Payload: payload, Force: true})
+		if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+			t.Errorf("%s: got %+v, want %s", payload, resp, ErrorCodeInvalidPayload)
+		}
+	}
+}
+
+func TestNextWork(t *testing.T) {
+	tm := timer.New(0, timer.WithPhases(timer.PhaseDurations{Work: 25 * time.Minute}))
+	s := New(tm)
+	if resp := send(t, s, Request{Type: RequestTypeNextWork, Payload: "50m"}); !resp.Success {
+		t.Fatalf("got %+v, want success", resp)
+	}
+	resp := send(t, s, Request{Type: RequestTypeSetDuration})
+	defer tm.Pause()
+	if !resp.Success || resp.Status.Duration != 50*time.Minute {
+		t.Errorf("got %+v, want a 50m work session", resp)
+	}
+	if resp := send(t, s, Request{Type: RequestTypeReset}); !resp.Success || tm.Status().Duration != 25*time.Minute {
+		t.Errorf("got %v after the override was used, want 25m", tm.Status().Duration)
+	}
+
+	for _, payload := range []string{"", "-5m", "soon"} {
+		resp := send(t, s, Request{Type: RequestTypeNextWork, Payload: payload})
+		if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+			t.Errorf("%q: got %+v, want %s", payload, resp, ErrorCodeInvalidPayload)
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	tm := timer.New(0, timer.WithPhases(timer.PhaseDurations{Work: 25 * time.Minute}))
+	s := New(tm)
+	defer tm.Pause()
+
+	for _, step := range []struct {
+		req     Request
+		changed bool
+	}{
+		{Request{Type: RequestTypeReset, Payload: "0s"}, true}, // The initial duration becomes zero
+		{Request{Type: RequestTypeReset, Payload: "0s"}, false},
+		{Request{Type: RequestTypeResumeAll}, false},
+		{Request{Type: RequestTypeNextWork, Payload: "50m"}, true},
+		{Request{Type: RequestTypeNextWork, Payload: "50m"}, false},
+		{Request{Type: RequestTypeSetInitial, Payload: "25m"}, true},
+		{Request{Type: RequestTypeSetInitial, Payload: "25m"}, false},
+		{Request{Type: RequestTypeConfigure, Payload: `{"work":"25m"}`}, false},
+		{Request{Type: RequestTypeStart}, true},
+		{Request{Type: RequestTypePauseAll}, true},
+		{Request{Type: RequestTypePauseAll}, false},
+		{Request{Type: RequestTypeAddSeconds, Payload: "0"}, false},
+		{Request{Type: RequestTypeAddSeconds, Payload: "60"}, true},
+		{Request{Type: RequestTypeConfigure, Payload: `{"apply_now":true}`}, true}, // Drops the minute added
+	} {
+		resp := send(t, s, step.req)
+		if !resp.Success || resp.Changed == nil || *resp.Changed != step.changed {
+			t.Errorf("%s %q: got %+v, want changed %t", step.req.Type, step.req.Payload, resp, step.changed)
+		}
+	}
+	if resp := send(t, s, Request{Type: RequestTypeStatus}); resp.Changed != nil {
+		t.Errorf("status: got changed %t, want it left out", *resp.Changed)
+	}
+}
+
+func TestClearHistoryChanged(t *testing.T) {
+	// No history file, so only the pomodoro counter can be cleared.
+	tm := timer.New(time.Minute, timer.WithPhases(timer.PhaseDurations{Work: time.Minute, ShortBreak: 5 * time.Minute}))
+	s := New(tm)
+	defer tm.Pause()
+	tm.Add(-time.Minute) // Completes the work session
+
+	for _, changed := range []bool{true, false} {
+		resp := send(t, s, Request{Type: RequestTypeClearHistory})
+		if !resp.Success || resp.Changed == nil || *resp.Changed != changed {
+			t.Errorf("got %+v, want changed %t", resp, changed)
+		}
+	}
+}
+
+func TestToggleMute(t *testing.T) {
+	tm := timer.New(0)
+	s := New(tm)
+	for _, want := range []bool{true, false} {
+		resp := send(t, s, Request{Type: RequestTypeToggleMute})
+		if !resp.Success || resp.Muted == nil || *resp.Muted != want || tm.Muted() != want {
+			t.Errorf("got %+v, want muted %t", resp, want)
+		}
+	}
+}
+
+func TestResetDuration(t *testing.T) {
+	tm := timer.New(10*time.Minute, timer.WithPhases(timer.PhaseDurations{Work: 10 * time.Minute}))
+	s := New(tm)
+	defer tm.Pause()
+
+	resp := send(t, s, Request{Type: RequestTypeReset, Payload: "50m"})
+	if !resp.Success || resp.Message != "Timer reset to 50m0s." || resp.Status == nil || resp.Status.Duration != 50*time.Minute {
+		t.Fatalf("got %+v, want a 50m work session", resp)
+	}
+	if got := tm.PhaseDurations().Work; got != 50*time.Minute {
+		t.Errorf("initial duration %v, want 50m", got)
+	}
+
+	// Zero resets to idle, and so do plain resets after it.
+	resp = send(t, s, Request{Type: RequestTypeReset, Payload: "0s"})
+	if !resp.Success || resp.Status == nil || resp.Status.State != timer.StateIdle {
+		t.Fatalf("got %+v, want idle", resp)
+	}
+	if got := tm.PhaseDurations().Work; got != 0 {
+		t.Errorf("initial duration %v, want zero", got)
+	}
+	if resp := send(t, s, Request{Type: RequestTypeReset}); resp.Status == nil || resp.Status.State != timer.StateIdle {
+		t.Errorf("plain reset got %+v, want still idle", resp)
+	}
+
+	for _, payload := range []string{"-5m", "soon"} {
+		resp := send(t, s, Request{Type: RequestTypeReset, Payload: payload})
+		if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+			t.Errorf("%s: got %+v, want %s", payload, resp, ErrorCodeInvalidPayload)
+		}
+	}
+	if state := tm.Status().State; state != timer.StateIdle {
+		t.Errorf("refused resets changed the state to %s", state)
+	}
+}
+
+func TestResetFocusLockIdle(t *testing.T) {
+	if resp := send(t, New(timer.New(0), WithFocusLock(true)), Request{Type: RequestTypeReset}); !resp.Success {
+		t.Errorf("got %+v, want reset allowed while idle", resp)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	s := New(tm, WithFocusLock(true), WithAddLimit(1))
+	send(t, s, Request{Type: RequestTypeNudge}) // Use up the rate limit
+
+	tests := []struct {
+		name string
+		raw  string
+		code string
+	}{
+		{"malformed", "{not json\n", ErrorCodeInvalidRequest},
+		{"unknown type", `{"type":"bogus"}` + "\n", ErrorCodeUnknownType},
+		{"invalid payload", `{"type":"add_seconds","payload":"abc"}` + "\n", ErrorCodeInvalidPayload},
+		{"rate limited", `{"type":"add_seconds","payload":"1"}` + "\n", ErrorCodeRateLimited},
+		{"focus locked", `{"type":"reset"}` + "\n", ErrorCodeFocusLocked},
+	}
+	for _, tt := range tests {
+		resp := roundTrip(t, s, tt.raw)
+		if resp.Success || resp.Error == nil {
+			t.Errorf("%s: got %+v, want a structured error", tt.name, resp)
+			continue
+		}
+		if resp.Error.Code != tt.code || resp.Error.Detail != resp.Message {
+			t.Errorf("%s: got error %+v with message %q, want code %q", tt.name, *resp.Error, resp.Message, tt.code)
+		}
+	}
+
+	if resp := send(t, s, Request{Type: RequestTypeStatus}); resp.Error != nil {
+		t.Errorf("successful status carries error %+v", *resp.Error)
+	}
+}
+
+func TestLongPollStatus(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		tm.AddSeconds(60)
+	}()
+
+	start := time.Now()
+	resp := send(t, New(tm), Request{Type: RequestTypeLongPollStatus, Payload: "1s"})
+	if !resp.Success || resp.Status.Duration != 11*time.Minute {
+		t.Fatalf("got %+v, want the status after the add", resp)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("long poll took %v, want it to return on the change", elapsed)
+	}
+}
+
+func TestLongPollStatusTimeout(t *testing.T) {
+	start := time.Now()
+	resp := send(t, New(timer.New(10*time.Minute)), Request{Type: RequestTypeLongPollStatus, Payload: "100ms"})
+	if !resp.Success || resp.Status.Duration != 10*time.Minute {
+		t.Fatalf("got %+v, want the unchanged status", resp)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("long poll returned after %v, want it to wait for the timeout", elapsed)
+	}
+
+	if resp := send(t, New(timer.New(0)), Request{Type: RequestTypeLongPollStatus, Payload: "soon"}); resp.Success {
+		t.Errorf("got %+v, want invalid wait rejected", resp)
+	}
+}
+
+func TestFramedProtocol(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go New(timer.New(10 * time.Minute)).HandleConnection(server)
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if err := json.NewEncoder(client).Encode(Request{Type: RequestTypeProtocol, Payload: ProtocolFramed}); err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := json.NewDecoder(client).Decode(&resp); err != nil || !resp.Success {
+		t.Fatalf("switching protocol: %+v, %v", resp, err)
+	}
+
+	writeFrame := func(body []byte) {
+		t.Helper()
+		frame := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+		if _, err := client.Write(append(frame, body...)); err != nil {
+			t.Fatalf("writing frame: %v", err)
+		}
+	}
+	readFrame := func() Response {
+		t.Helper()
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(client, header); err != nil {
+			t.Fatalf("reading frame header: %v", err)
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(client, body); err != nil {
+			t.Fatalf("reading frame body: %v", err)
+		}
+		var resp Response
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("decoding frame: %v", err)
+		}
+		return resp
+	}
+
+	// A malformed body is rejected without losing the framing.
+	writeFrame([]byte("{nope"))
+	if resp := readFrame(); resp.Success || resp.Error == nil || resp.Error.Code != ErrorCodeInvalidRequest {
+		t.Fatalf("malformed frame: got %+v", resp)
+	}
+
+	body, _ := json.Marshal(Request{Type: RequestTypeStatus})
+	writeFrame(body)
+	if resp := readFrame(); !resp.Success || resp.Status.Duration != 10*time.Minute {
+		t.Errorf("framed status: got %+v", resp)
+	}
+
+	body, _ = json.Marshal(Request{Type: RequestTypeWhoami})
+	writeFrame(body)
+	if resp := readFrame(); !resp.Success || resp.Whoami == nil || resp.Whoami.Protocol != ProtocolFramed {
+		t.Errorf("framed whoami: got %+v", resp)
+	}
+}
+
+func TestGetRemaining(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go New(timer.New(90 * time.Second)).HandleConnection(server)
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+
+	json.NewEncoder(client).Encode(Request{Type: RequestTypeGetRemaining})
+	line, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"success":true,"remaining":90,"state":"c"}` + "\n"; line != want {
+		t.Errorf("got %s, want %s", line, want)
+	}
+
+	resp := send(t, New(timer.New(0)), Request{Type: RequestTypeGetRemaining})
+	if resp.Remaining == nil || *resp.Remaining != 0 || resp.State != "i" {
+		t.Errorf("idle: got %+v, want 0 seconds and state i", resp)
+	}
+}
+
+func TestGetRemainingStates(t *testing.T) {
+	paused := timer.New(time.Minute)
+	paused.Pause()
+	scheduled := timer.New(0)
+	scheduled.ScheduleStart(time.Hour)
+	startingBreak := timer.New(time.Second, timer.WithPhases(timer.PhaseDurations{
+		Work: time.Second, ShortBreak: time.Minute, BreakStartDelay: time.Hour,
+	}))
+	startingBreak.Add(-time.Second) // Completes the work session
+
+	seen := map[string]timer.State{}
+	for _, tm := range []*timer.Timer{timer.New(0), timer.New(time.Minute), paused, scheduled, startingBreak} {
+		state := tm.Status().State
+		resp := send(t, New(tm), Request{Type: RequestTypeGetRemaining})
+		if resp.State != stateCodes[state] {
+			t.Errorf("%s: got code %q, want %q", state, resp.State, stateCodes[state])
+		}
+		if other, ok := seen[resp.State]; ok {
+			t.Errorf("%s and %s share the code %q", state, other, resp.State)
+		}
+		seen[resp.State] = state
+	}
+	if len(seen) != len(stateCodes) {
+		t.Errorf("covered %d states, want all %d", len(seen), len(stateCodes))
+	}
+}
+
+func TestStatusSince(t *testing.T) {
+	tm := timer.New(90*time.Second + 500*time.Millisecond) // Not started, so the time stays put
+	s := New(tm)
+
+	tests := []struct {
+		payload     string
+		notModified bool
+	}{
+		{`{"state":"countdown","phase":"work","remaining":90}`, true},
+		{`{"state":"countdown","phase":"work","remaining":91}`, false},
+		{`{"state":"paused","phase":"work","remaining":90}`, false},
+		{`{"state":"countdown","phase":"short_break","remaining":90}`, false},
+	}
+	for _, tt := range tests {
+		resp := send(t, s, Request{Type: RequestTypeStatusSince, Payload: tt.payload})
+		if !resp.Success || resp.NotModified != tt.notModified || (resp.Status == nil) != tt.notModified {
+			t.Errorf("since %s: got %+v, want not modified %v", tt.payload, resp, tt.notModified)
+		}
+	}
+
+	if resp := send(t, s, Request{Type: RequestTypeStatusSince, Payload: "90"}); resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+		t.Errorf("got %+v, want an invalid payload error", resp)
+	}
+}
+
+func TestPauseResumeAll(t *testing.T) {
+	tm := timer.New(0)
+	tm.AddSeconds(600) // Starts the countdown
+
+	if resp := send(t, New(tm), Request{Type: RequestTypePauseAll}); !resp.Success || resp.Message != "Paused 1 timers." {
+		t.Fatalf("pause: got %+v", resp)
+	}
+	if resp := send(t, New(tm), Request{Type: RequestTypePauseAll}); resp.Message != "Paused 0 timers." {
+		t.Errorf("second pause: got %+v, want nothing affected", resp)
+	}
+	if status := tm.Status(); status.State != timer.StatePaused || status.PauseReason != timer.DefaultPauseReason {
+		t.Errorf("got %+v, want paused by hand", status)
+	}
+
+	if resp := send(t, New(tm), Request{Type: RequestTypeResumeAll}); !resp.Success || resp.Message != "Resumed 1 timers." {
+		t.Fatalf("resume: got %+v", resp)
+	}
+	if status := tm.Status(); status.State != timer.StateCountdown || status.PauseReason != "" {
+		t.Errorf("got %+v, want counting down again", status)
+	}
+
+	if resp := send(t, New(tm), Request{Type: RequestTypePauseAll, Payload: strings.Repeat("x", maxPauseReason+1)}); resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+		t.Errorf("long reason: got %+v, want %s", resp, ErrorCodeInvalidPayload)
+	}
+	send(t, New(tm), Request{Type: RequestTypePauseAll, Payload: " meeting "})
+	if status := tm.Status(); status.PauseReason != "meeting" {
+		t.Errorf("got reason %q, want meeting", status.PauseReason)
+	}
+	tm.Resume()
+}
+
+func TestStartScheduled(t *testing.T) {
+	tm := timer.New(0)
+
+	resp := send(t, New(tm), Request{Type: RequestTypeStart, Payload: "1h"})
+	if !resp.Success || resp.Message != "Starting in 1h0m0s." {
+		t.Fatalf("schedule: got %+v", resp)
+	}
+	status := tm.Status()
+	if status.State != timer.StateScheduled || status.StartsIn <= 59*time.Minute || status.StartsIn > time.Hour {
+		t.Errorf("got %+v, want scheduled to start in about an hour", status)
+	}
+
+	// Starting now replaces the pending schedule.
+	if resp := send(t, New(tm), Request{Type: RequestTypeStart}); !resp.Success || resp.Message != "Timer started." {
+		t.Fatalf("start: got %+v", resp)
+	}
+	status = tm.Status()
+	if status.State != timer.StateCountdown || status.Phase != timer.PhaseWork || status.StartsIn != 0 {
+		t.Errorf("got %+v, want a work countdown", status)
+	}
+
+	resp = send(t, New(tm), Request{Type: RequestTypeStart})
+	if resp.Success || resp.Error == nil || resp.Error.Code != ErrorCodeNotIdle {
+		t.Errorf("start while running: got %+v, want %s", resp, ErrorCodeNotIdle)
+	}
+}
+
+func TestStartScheduledFires(t *testing.T) {
+	tm := timer.New(0)
+	if !tm.ScheduleStart(50 * time.Millisecond) {
+		t.Fatal("ScheduleStart refused an idle timer")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if status := tm.Status(); status.State != timer.StateCountdown {
+		t.Errorf("got %+v, want the scheduled countdown running", status)
+	}
+}
+
+func TestStartedAt(t *testing.T) {
+	tm := timer.New(0)
+	if status := tm.Status(); !status.StartedAt.IsZero() {
+		t.Fatalf("idle: got started at %v, want zero", status.StartedAt)
+	}
+
+	before := time.Now()
+	send(t, New(tm), Request{Type: RequestTypeAddSeconds, Payload: "600"})
+	started := tm.Status().StartedAt
+	if started.Before(before) || started.After(time.Now()) {
+		t.Fatalf("got started at %v, want the time of the first add", started)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	send(t, New(tm), Request{Type: RequestTypeAddSeconds, Payload: "60"})
+	if got := tm.Status().StartedAt; !got.Equal(started) {
+		t.Errorf("add moved started at from %v to %v", started, got)
+	}
+
+	send(t, New(tm), Request{Type: RequestTypeReset})
+	if got := tm.Status().StartedAt; !got.After(started) {
+		t.Errorf("reset left started at %v, want it updated", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	history := timer.NewHistory(t.TempDir() + "/history.jsonl")
+	record := timer.HistoryRecord{
+		Timestamp: time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC),
+		Phase:     timer.PhaseWork,
+		Duration:  25 * time.Minute,
+	}
+	if err := history.Append(record); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := send(t, New(timer.New(0, timer.WithHistory(history))), Request{Type: RequestTypeHistory})
+	if !resp.Success || len(resp.History) != 1 {
+		t.Fatalf("got %+v, want the one record", resp)
+	}
+	if got := resp.History[0]; !got.Timestamp.Equal(record.Timestamp) || got.Phase != record.Phase || got.Duration != record.Duration {
+		t.Errorf("got %+v, want %+v", got, record)
+	}
+}
+
+func TestStreak(t *testing.T) {
+	history := timer.NewHistory(t.TempDir() + "/history.jsonl")
+	for _, days := range []int{0, 1, 3} {
+		history.Append(timer.HistoryRecord{Timestamp: time.Now().AddDate(0, 0, -days), Phase: timer.PhaseWork, Duration: time.Minute})
+	}
+
+	resp := send(t, New(timer.New(0, timer.WithHistory(history))), Request{Type: RequestTypeStreak})
+	if !resp.Success || resp.Streak == nil || *resp.Streak != (timer.Streak{Current: 2, Longest: 2}) {
+		t.Errorf("got %+v, want current and longest streaks of 2", resp)
+	}
+}
+
+func TestGoal(t *testing.T) {
+	history := timer.NewHistory(t.TempDir() + "/history.jsonl")
+	for _, days := range []int{0, 0, 1} {
+		history.Append(timer.HistoryRecord{Timestamp: time.Now().AddDate(0, 0, -days), Phase: timer.PhaseWork, Duration: time.Minute})
+	}
+
+	resp := send(t, New(timer.New(0, timer.WithHistory(history), timer.WithDailyGoal(8))), Request{Type: RequestTypeGoal})
+	if !resp.Success || resp.Goal == nil || *resp.Goal != (timer.Goal{Completed: 2, Target: 8, Percent: 25}) {
+		t.Errorf("got %+v, want 2 of 8 completed", resp)
+	}
+}
+
+func TestStats(t *testing.T) {
+	history := timer.NewHistory(t.TempDir() + "/history.jsonl")
+	for _, days := range []int{0, 0, 2, 9} {
+		history.Append(timer.HistoryRecord{Timestamp: time.Now().AddDate(0, 0, -days), Phase: timer.PhaseWork, Duration: time.Minute})
+	}
+	s := New(timer.New(0, timer.WithHistory(history)))
+
+	resp := send(t, s, Request{Type: RequestTypeStats})
+	if !resp.Success || len(resp.Stats) != DefaultStatsDays {
+		t.Fatalf("got %+v, want %d days", resp, DefaultStatsDays)
+	}
+	if today := resp.Stats[DefaultStatsDays-1]; today.Sessions != 2 || today.Focused != 2*time.Minute {
+		t.Errorf("today got %+v, want 2 sessions", today)
+	}
+	if resp := send(t, s, Request{Type: RequestTypeStats, Payload: `{"days":10}`}); len(resp.Stats) != 10 || resp.Stats[0].Sessions != 1 {
+		t.Errorf("got %+v, want 10 days starting with one session", resp)
+	}
+
+	for _, payload := range []string{`{"days":0}`, `{"days":400}`, "week"} {
+		resp := send(t, s, Request{Type: RequestTypeStats, Payload: payload})
+		if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+			t.Errorf("%s: got %+v, want %s", payload, resp, ErrorCodeInvalidPayload)
+		}
+	}
+}
+
+func TestStatsGoalSkipOldFiles(t *testing.T) {
+	dir := t.TempDir()
+	history := timer.NewDailyHistory(dir)
+	history.Append(timer.HistoryRecord{Timestamp: time.Now(), Phase: timer.PhaseWork, Duration: time.Minute})
+	// A damaged day file from long before the range asked for.
+	old := filepath.Join(dir, time.Now().AddDate(0, 0, -30).Format("2006-01-02")+".jsonl")
+	if err := os.WriteFile(old, []byte("not json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := New(timer.New(0, timer.WithHistory(history), timer.WithDailyGoal(2)))
+
+	resp := send(t, s, Request{Type: RequestTypeStats, Payload: `{"days":7}`})
+	if !resp.Success || len(resp.Stats) != 7 || resp.Stats[6].Sessions != 1 {
+		t.Errorf("stats: got %+v, want 7 days with one session today", resp)
+	}
+	resp = send(t, s, Request{Type: RequestTypeGoal})
+	if !resp.Success || resp.Goal == nil || resp.Goal.Completed != 1 {
+		t.Errorf("goal: got %+v, want 1 completed", resp)
+	}
+	if resp := send(t, s, Request{Type: RequestTypeHistory}); resp.Success {
+		t.Error("history: got success, want the damaged file reported")
+	}
+}
+
+func TestSetInitial(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	s := New(tm)
+
+	resp := send(t, s, Request{Type: RequestTypeSetInitial, Payload: "25m"})
+	if !resp.Success || resp.Config == nil || resp.Config.Work != 25*time.Minute {
+		t.Fatalf("got %+v, want the new initial duration", resp)
+	}
+	if got := tm.Status().Duration; got != 10*time.Minute {
+		t.Errorf("running countdown changed to %v", got)
+	}
+	tm.Reset()
+	tm.Pause()
+	if got := tm.Status().Duration; got != 25*time.Minute {
+		t.Errorf("after a reset got %v, want 25m", got)
+	}
+
+	for _, payload := range []string{"", "0s", "-5m", "soon"} {
+		resp := send(t, s, Request{Type: RequestTypeSetInitial, Payload: payload})
+		if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+			t.Errorf("%q: got %+v, want %s", payload, resp, ErrorCodeInvalidPayload)
+		}
+	}
+}
+
+func TestImportHistory(t *testing.T) {
+	history := timer.NewDailyHistory(t.TempDir())
+	tm := timer.New(0, timer.WithHistory(history))
+	s := New(tm)
+
+	now := time.Now()
+	records := []timer.HistoryRecord{
+		{Timestamp: now.Add(-time.Minute), Phase: timer.PhaseWork, Duration: 25 * time.Minute},
+		{Timestamp: now.AddDate(0, 0, -3), Duration: 50 * time.Minute}, // No phase: work
+	}
+	payload, _ := json.Marshal(records)
+	resp := send(t, s, Request{Type: RequestTypeImportHistory, Payload: string(payload)})
+	if !resp.Success || resp.Message != "Imported 2 history records." {
+		t.Fatalf("got %+v, want 2 records imported", resp)
+	}
+	if got, err := history.Records(); err != nil || len(got) != 2 || !got[0].Timestamp.Before(got[1].Timestamp) {
+		t.Errorf("history has %+v (%v), want both records oldest first", got, err)
+	}
+	if got := tm.Status().FocusedToday; got != 25*time.Minute {
+		t.Errorf("focused today %v, want the 25m imported for today", got)
+	}
+
+	for _, payload := range []string{
+		`nope`,
+		`[{"timestamp":"2020-01-01T10:00:00Z","duration":0}]`,
+		`[{"duration":1500000000000}]`,
+		`[{"timestamp":"2999-01-01T10:00:00Z","duration":1500000000000}]`,
+		`[{"timestamp":"2020-01-01T10:00:00Z","phase":"nap","duration":1500000000000}]`,
+	} {
+		resp := send(t, s, Request{Type: RequestTypeImportHistory, Payload: payload})
+		if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+			t.Errorf("%s: got %+v, want %s", payload, resp, ErrorCodeInvalidPayload)
+		}
+	}
+
+	resp = send(t, New(timer.New(0)), Request{Type: RequestTypeImportHistory, Payload: string(payload)})
+	if resp.Success || resp.Error.Code != ErrorCodeNoHistory {
+		t.Errorf("without history: got %+v, want %s", resp, ErrorCodeNoHistory)
+	}
+}
+
+func TestPingInfo(t *testing.T) {
+	s := New(timer.New(0))
+	if resp := send(t, s, Request{Type: RequestTypePing}); !resp.Success || resp.Message != "pong" {
+		t.Errorf("ping: got %+v, want pong", resp)
+	}
+
+	resp := send(t, s, Request{Type: RequestTypeInfo})
+	if !resp.Success || resp.Info == nil || resp.Info.Version == "" || resp.Info.StartedAt.IsZero() || resp.Info.Uptime < 0 {
+		t.Errorf("info: got %+v, want the version and start time", resp)
+	}
+}
+
+func TestRemoteToken(t *testing.T) {
+	remote := func(s *Server, req Request) Response {
+		t.Helper()
+		client, server := net.Pipe()
+		defer client.Close()
+		go s.HandleRemoteConnection(server)
+
+		client.SetDeadline(time.Now().Add(2 * time.Second))
+		if err := json.NewEncoder(client).Encode(req); err != nil {
+			t.Fatalf("writing request: %v", err)
+		}
+		var resp Response
+		if err := json.NewDecoder(client).Decode(&resp); err != nil {
+			t.Fatalf("reading response: %v", err)
+		}
+		return resp
+	}
+
+	s := New(timer.New(0), WithToken("secret"))
+	tests := []struct {
+		token string
+		ok    bool
+	}{
+		{"", false},
+		{"wrong", false},
+		{"secret", true},
+	}
+	for _, tt := range tests {
+		resp := remote(s, Request{Type: RequestTypePing, Token: tt.token})
+		if resp.Success != tt.ok {
+			t.Errorf("token %q: got %+v, want success %t", tt.token, resp, tt.ok)
+		}
+		if !tt.ok && (resp.Error == nil || resp.Error.Code != ErrorCodeUnauthorized) {
+			t.Errorf("token %q: got %+v, want %s", tt.token, resp.Error, ErrorCodeUnauthorized)
+		}
+	}
+
+	// Without a token configured nothing gets in remotely, while local
+	// connections need none.
+	s = New(timer.New(0))
+	if resp := remote(s, Request{Type: RequestTypePing}); resp.Success {
+		t.Errorf("remote without a configured token: got %+v, want refused", resp)
+	}
+	if resp := send(t, s, Request{Type: RequestTypePing}); !resp.Success {
+		t.Errorf("local: got %+v, want pong", resp)
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	s := New(timer.New(0), WithToken("secret"))
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "pomidoras.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.HandleConnection(conn)
+		}
+	}()
+
+	whoami := func(req Request) *Whoami {
+		t.Helper()
+		conn, err := net.Dial("unix", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		var resp Response
+		if err := json.NewEncoder(conn).Encode(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.NewDecoder(conn).Decode(&resp); err != nil || !resp.Success || resp.Whoami == nil {
+			t.Fatalf("got %+v, %v", resp, err)
+		}
+		return resp.Whoami
+	}
+
+	got := whoami(Request{Type: RequestTypeWhoami})
+	if got.Remote || got.Authorized || got.Protocol != ProtocolJSON || got.ServerUID != os.Getuid() {
+		t.Errorf("got %+v, want a local unauthorized JSON connection", got)
+	}
+	if runtime.GOOS == "linux" && (got.UID == nil || *got.UID != os.Getuid()) {
+		t.Errorf("got peer UID %v, want %d", got.UID, os.Getuid())
+	}
+	if got := whoami(Request{Type: RequestTypeWhoami, Token: "secret"}); !got.Authorized {
+		t.Errorf("with the token: got %+v, want authorized", got)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	history := timer.NewHistory(t.TempDir() + "/history.jsonl")
+	resp := send(t, New(timer.New(0, timer.WithHistory(history))), Request{Type: RequestTypeCapabilities})
+	if !resp.Success || resp.Capabilities == nil {
+		t.Fatalf("got %+v, want capabilities", resp)
+	}
+	features := resp.Capabilities.Features
+	if !features["history"] || !features["phases"] || features["logs"] {
+		t.Errorf("got features %v, want history and phases without logs", features)
+	}
+
+	// Every listed type must be handled.
+	payloads := map[RequestType]string{RequestTypeLongPollStatus: "0s"}
+	for _, reqType := range resp.Capabilities.RequestTypes {
+		resp := New(timer.New(0)).handleRequest(Request{Type: reqType, Payload: payloads[reqType]})
+		if resp.Error != nil && resp.Error.Code == ErrorCodeUnknownType {
+			t.Errorf("%s is listed but not handled", reqType)
+		}
+	}
+}
+
+func TestAddPercent(t *testing.T) {
+	tm := timer.New(10 * time.Minute) // Not started, so the time stays put
+
+	resp := send(t, New(tm), Request{Type: RequestTypeAddPercent, Payload: "10"})
+	if !resp.Success || resp.Message != "Added 60 seconds." {
+		t.Fatalf("got %+v, want 60 seconds added", resp)
+	}
+	if got := tm.Status().Duration; got != 11*time.Minute {
+		t.Errorf("got %v remaining, want 11m", got)
+	}
+
+	// Clamped to -100%, and never below zero.
+	resp = send(t, New(tm), Request{Type: RequestTypeAddPercent, Payload: "-250"})
+	if !resp.Success || resp.Message != "Added -600 seconds." {
+		t.Errorf("got %+v, want 600 seconds removed", resp)
+	}
+	// Taking what is left completes the session.
+	resp = send(t, New(tm), Request{Type: RequestTypeAddPercent, Payload: "-100"})
+	defer tm.Pause()
+	if status := tm.Status(); !resp.Success || resp.Message != "Added -60 seconds." || status.Phase != timer.PhaseShortBreak {
+		t.Errorf("got %+v and %+v, want the last 60 seconds removed and the break started", resp, status)
+	}
+
+	resp = send(t, New(tm), Request{Type: RequestTypeAddPercent, Payload: "lots"})
+	if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+		t.Errorf("got %+v, want %s", resp, ErrorCodeInvalidPayload)
+	}
+
+	noInitial := timer.New(0, timer.WithPhases(timer.PhaseDurations{}))
+	resp = send(t, New(noInitial), Request{Type: RequestTypeAddPercent, Payload: "10"})
+	if resp.Success {
+		t.Errorf("without an initial duration: got %+v, want an error", resp)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	tm := timer.New(0)
+	tm.AddSeconds(600) // A running 10 minute session
+	defer tm.Pause()
+
+	resp := send(t, New(tm), Request{Type: RequestTypeConfigure, Payload: `{"work":"50m","short_break":"0s","break_start_delay":"10s"}`})
+	if !resp.Success || resp.Config == nil {
+		t.Fatalf("got %+v, want the new configuration", resp)
+	}
+	want := timer.DefaultPhaseDurations
+	want.Work = 50 * time.Minute
+	want.ShortBreak = 0
+	want.BreakStartDelay = 10 * time.Second
+	if *resp.Config != want || tm.PhaseDurations() != want {
+		t.Errorf("got %+v, want %+v", *resp.Config, want)
+	}
+	if got := tm.Status().Duration; got > 10*time.Minute {
+		t.Errorf("running session changed to %v without apply_now", got)
+	}
+
+	send(t, New(tm), Request{Type: RequestTypeConfigure, Payload: `{"work":"20m","apply_now":true}`})
+	if got := tm.Status().Duration; got <= 19*time.Minute || got > 20*time.Minute {
+		t.Errorf("got %v remaining, want the running session stretched to about 20m", got)
+	}
+
+	for _, payload := range []string{`{"work":"0s"}`, `{"long_break":"soon"}`, `{"long_break_interval":-1}`, `{"break_start_delay":"-10s"}`, `nope`} {
+		resp := send(t, New(tm), Request{Type: RequestTypeConfigure, Payload: payload})
+		if resp.Success || resp.Error.Code != ErrorCodeInvalidPayload {
+			t.Errorf("%s: got %+v, want %s", payload, resp, ErrorCodeInvalidPayload)
+		}
+	}
+}
+
+func TestNextReminder(t *testing.T) {
+	tm := timer.New(10*time.Minute, timer.WithReminders(5*time.Minute))
+	resp := send(t, New(tm), Request{Type: RequestTypeNextReminder})
+	if !resp.Success || resp.Reminder == nil || resp.Reminder.Left != 5*time.Minute {
+		t.Fatalf("got %+v, want the 5m reminder", resp)
+	}
+
+	tm.AddSeconds(-6 * 60)
+	resp = send(t, New(tm), Request{Type: RequestTypeNextReminder})
+	if !resp.Success || resp.Reminder != nil || resp.Message != "none" {
+		t.Errorf("got %+v, want none", resp)
+	}
+}
+
+func TestMinRemaining(t *testing.T) {
+	tm := timer.New(10*time.Minute, timer.WithMinRemaining(5*time.Minute))
+	for _, req := range []Request{
+		{Type: RequestTypeAddSeconds, Payload: "-360"},
+		{Type: RequestTypeAddPercent, Payload: "-60"},
+	} {
+		resp := send(t, New(tm), req)
+		if resp.Success || resp.Error == nil || resp.Error.Code != ErrorCodeBelowMinimum {
+			t.Errorf("%s %s: got %+v, want below_minimum", req.Type, req.Payload, resp)
+		}
+	}
+	if resp := send(t, New(tm), Request{Type: RequestTypeAddSeconds, Payload: "-300"}); !resp.Success {
+		t.Errorf("got %+v, want subtracting down to the floor allowed", resp)
+	}
+}
+
+func TestSubscribeFanOut(t *testing.T) {
+	const subscribers = 100
+	const maxLag = 500 * time.Millisecond
+
+	tm := timer.New(10 * time.Minute)
+	s := New(tm)
+	defer tm.Pause()
+
+	decoders := make([]*json.Decoder, subscribers)
+	for i := range decoders {
+		client, server := net.Pipe()
+		defer client.Close()
+		go s.HandleConnection(server)
+		client.SetDeadline(time.Now().Add(10 * time.Second))
+
+		json.NewEncoder(client).Encode(Request{Type: RequestTypeSubscribe})
+		decoders[i] = json.NewDecoder(client)
+		var ack Response
+		if err := decoders[i].Decode(&ack); err != nil || !ack.Success {
+			t.Fatalf("subscriber %d: got %+v (%v), want success", i, ack, err)
+		}
+	}
+	tm.Start()
+
+	// Every subscriber should see the start and the next two ticks.
+	received := make([][]timer.Event, subscribers)
+	var wg sync.WaitGroup
+	for i, decoder := range decoders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for len(received[i]) < 3 {
+				var resp Response
+				if err := decoder.Decode(&resp); err != nil || resp.Event == nil {
+					t.Errorf("subscriber %d: got %+v (%v), want an event", i, resp, err)
+					return
+				}
+				if lag := time.Since(resp.Event.At); lag > maxLag {
+					t.Errorf("subscriber %d got %s %v after it happened, want within %v", i, resp.Event.Type, lag, maxLag)
+				}
+				received[i] = append(received[i], *resp.Event)
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	first := received[0]
+	for i, typ := range []timer.EventType{timer.EventStarted, timer.EventTick, timer.EventTick} {
+		if first[i].Type != typ {
+			t.Fatalf("got events %+v, want started and two ticks", first)
+		}
+	}
+	for i, events := range received[1:] {
+		for j, event := range events {
+			if event.Type != first[j].Type || event.Remaining != first[j].Remaining || !event.At.Equal(first[j].At) {
+				t.Errorf("subscriber %d event %d: got %+v, want %+v like the others", i+1, j, event, first[j])
+			}
+		}
+	}
+}
+
+func TestClientLeavesMidBatch(t *testing.T) {
+	tm := timer.New(10 * time.Minute) // Not started, so the time stays put
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		New(tm).HandleConnection(server)
+		close(done)
+	}()
+
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+	// net.Pipe is unbuffered, so the batch is written as the server reads it.
+	batch := strings.Repeat(`{"type":"add_seconds","payload":"60"}`+"\n", 3)
+	go io.WriteString(client, batch)
+	var resp Response
+	if err := json.NewDecoder(client).Decode(&resp); err != nil || !resp.Success {
+		t.Fatalf("got %+v (%v), want the first response", resp, err)
+	}
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection still served after the client left")
+	}
+	// The second add may or may not have been read before the client left,
+	// but with its response undeliverable the third is never served.
+	if got := tm.Status().Duration; got > 12*time.Minute {
+		t.Errorf("got %v remaining, want at most 12m", got)
+	}
+}
+
+func TestMaxConnections(t *testing.T) {
+	s := New(timer.New(0), WithMaxConnections(1))
+
+	// A connection that has been answered holds the only slot.
+	held, server := net.Pipe()
+	go s.HandleConnection(server)
+	held.SetDeadline(time.Now().Add(2 * time.Second))
+	json.NewEncoder(held).Encode(Request{Type: RequestTypeStatus})
+	var resp Response
+	if err := json.NewDecoder(held).Decode(&resp); err != nil || !resp.Success {
+		t.Fatalf("got %+v (%v), want the first connection served", resp, err)
+	}
+
+	resp = send(t, s, Request{Type: RequestTypeStatus})
+	if resp.Success || resp.Error.Code != ErrorCodeBusy {
+		t.Fatalf("got %+v, want %s", resp, ErrorCodeBusy)
+	}
+
+	// Leaving frees the slot.
+	held.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for !send(t, s, Request{Type: RequestTypeStatus}).Success {
+		if time.Now().After(deadline) {
+			t.Fatal("slot not freed after the connection closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// BenchmarkConcurrentClients hammers a Unix socket with clients that each
+// connect, ask for the status and leave, reporting how many were turned
+// away as busy under WithMaxConnections.
+func BenchmarkConcurrentClients(b *testing.B) {
+	for _, limit := range []int{0, 4} {
+		b.Run(fmt.Sprintf("max=%d", limit), func(b *testing.B) {
+			s := New(timer.New(0), WithMaxConnections(limit))
+			listener, err := net.Listen("unix", filepath.Join(b.TempDir(), "pomidoras.sock"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer listener.Close()
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					go s.HandleConnection(conn)
+				}
+			}()
+
+			var busy atomic.Int64
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					conn, err := net.Dial("unix", listener.Addr().String())
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					json.NewEncoder(conn).Encode(Request{Type: RequestTypeStatus})
+					var resp Response
+					err = json.NewDecoder(conn).Decode(&resp)
+					conn.Close()
+					switch {
+					case err != nil:
+						b.Error(err)
+						return
+					case resp.Error != nil && resp.Error.Code == ErrorCodeBusy:
+						busy.Add(1)
+					case !resp.Success:
+						b.Errorf("got %+v", resp)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(busy.Load())/float64(b.N), "busy/op")
+		})
+	}
+}
+
+func TestSubscribeHeartbeat(t *testing.T) {
+	tm := timer.New(0)
+	client, server := net.Pipe()
+	defer client.Close()
+	go New(tm, WithHeartbeat(50*time.Millisecond)).HandleConnection(server)
+
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+	json.NewEncoder(client).Encode(Request{Type: RequestTypeSubscribe})
+	decoder := json.NewDecoder(client)
+	var ack Response
+	if err := decoder.Decode(&ack); err != nil || !ack.Success || ack.Heartbeat {
+		t.Fatalf("got %+v (%v), want the subscription reply", ack, err)
+	}
+	// The idle timer sends no events, only heartbeats.
+	for range 2 {
+		var resp Response
+		if err := decoder.Decode(&resp); err != nil || !resp.Heartbeat || resp.Event != nil {
+			t.Fatalf("got %+v (%v), want a heartbeat", resp, err)
+		}
+	}
+}
+
+func TestSubscribeUnsubscribesOnClose(t *testing.T) {
+	tm := timer.New(10 * time.Minute)
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		New(tm).HandleConnection(server)
+		close(done)
+	}()
+
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+	json.NewEncoder(client).Encode(Request{Type: RequestTypeSubscribe})
+	var ack Response
+	if err := json.NewDecoder(client).Decode(&ack); err != nil || !ack.Success {
+		t.Fatalf("got %+v (%v), want success", ack, err)
+	}
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection still served after the subscriber left")
+	}
+}
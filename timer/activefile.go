@@ -0,0 +1,46 @@
+package timer
+
+import (
+	"fmt"
+	"os"
+)
+
+// WithActiveFile keeps an empty file at path that exists only while a work
+// session counts down, so shell scripts can check for one with [ -f path ]
+// instead of speaking the protocol. It is removed as soon as the session
+// completes, pauses or is abandoned, and any file left at path by an
+// earlier run is removed right away.
+func WithActiveFile(path string) Option {
+	return func(t *Timer) {
+		t.activeFile = path
+		if path == "" {
+			return
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error removing active file: %v\n", err)
+		}
+	}
+}
+
+// updateActiveFile creates or removes the WithActiveFile file if the timer
+// has started or stopped counting down work since the last call. Must be
+// called with t.mu held.
+func (t *Timer) updateActiveFile() {
+	if t.activeFile == "" {
+		return
+	}
+	active := t.phase == PhaseWork && t.state == StateCountdown
+	if active == t.activeFileOn {
+		return
+	}
+	t.activeFileOn = active
+	var err error
+	if active {
+		err = os.WriteFile(t.activeFile, nil, 0o644)
+	} else {
+		err = os.Remove(t.activeFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating active file: %v\n", err)
+	}
+}
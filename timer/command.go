@@ -0,0 +1,26 @@
+package timer
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// runShell runs command with sh -c and waits for it. Any args are appended
+// to it as "$@". A failure includes what the command printed.
+func runShell(command string, args ...string) error {
+	if len(args) > 0 {
+		command += ` "$@"`
+	}
+	var output bytes.Buffer
+	cmd := exec.Command("sh", append([]string{"-c", command, "sh"}, args...)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if err != nil {
+		if detail := bytes.TrimSpace(output.Bytes()); len(detail) > 0 {
+			err = fmt.Errorf("%v: %s", err, detail)
+		}
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package timer
+
+import (
+	"fmt"
+	"os"
+)
+
+// DND configures shell commands that toggle do-not-disturb as work sessions
+// start and end. An empty command is skipped.
+type DND struct {
+	On  string // Run when a work session starts
+	Off string // Run when work ends, into a break or idle
+}
+
+// dndQueueSize bounds the commands waiting to run. Toggles only happen on
+// phase changes, so a full queue means the commands are hanging.
+const dndQueueSize = 16
+
+// WithDND runs the commands in d, with sh -c, whenever the timer moves into
+// or out of a work session. A paused session still counts as work. Commands
+// run one at a time in order, off the timer's goroutine, and failures are
+// logged.
+func WithDND(d DND) Option {
+	return func(t *Timer) {
+		if d.On == "" && d.Off == "" {
+			return
+		}
+		t.dnd = d
+		t.dndQueue = make(chan string, dndQueueSize)
+		go runDND(t.dndQueue)
+	}
+}
+
+// updateDND queues the DND command if the timer has moved into or out of
+// work since the last call. Must be called with t.mu held.
+func (t *Timer) updateDND() {
+	if t.dndQueue == nil {
+		return
+	}
+	working := t.phase == PhaseWork && (t.state == StateCountdown || t.state == StatePaused)
+	if working == t.dndOn {
+		return
+	}
+	t.dndOn = working
+	command := t.dnd.Off
+	if working {
+		command = t.dnd.On
+	}
+	if command == "" {
+		return
+	}
+	select {
+	case t.dndQueue <- command:
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: DND commands are not finishing, skipping %q\n", command)
+	}
+}
+
+// runDND runs every command from queue in turn.
+func runDND(queue <-chan string) {
+	for command := range queue {
+		if err := runShell(command); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running DND command %q: %v\n", command, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package timer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDND(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "dnd")
+	tm := New(time.Second,
+		WithPhases(PhaseDurations{Work: time.Second, ShortBreak: time.Hour}),
+		WithDND(DND{On: "echo on >> " + log, Off: "echo off >> " + log}))
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			got, _ := os.ReadFile(log)
+			if string(got) == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	tm.Start()
+	tm.Pause() // Still work, so no toggle
+	tm.Resume()
+	waitFor("on\n")
+	// The work session ends into a break a second later.
+	waitFor("on\noff\n")
+}
@@ -0,0 +1,86 @@
+package timer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// EventType says what happened to a Timer.
+type EventType string
+
+const (
+	EventStarted      EventType = "started"       // A work session started counting down
+	EventTick         EventType = "tick"          // A second passed
+	EventCompleted    EventType = "completed"     // Phase finished
+	EventPaused       EventType = "paused"        // The countdown was paused
+	EventResumed      EventType = "resumed"       // A paused countdown continued
+	EventPhaseChanged EventType = "phase_changed" // The timer moved on to Phase after a completion; "" is idle
+)
+
+// Event describes one change to a Timer, as delivered by Events.
+type Event struct {
+	Type      EventType     `json:"type"`
+	Phase     Phase         `json:"phase,omitempty"` // The phase the event is about
+	Remaining time.Duration `json:"remaining"`       // Remaining time right after the event
+	At        time.Time     `json:"at"`
+}
+
+// eventBuffer is how many events a subscriber may fall behind by before
+// further events are dropped for it.
+const eventBuffer = 64
+
+// subscriber is one channel returned by Events.
+type subscriber struct {
+	ch       chan Event
+	dropping bool // Events are being dropped; warned about once until it catches up
+}
+
+// Events returns a new channel receiving every event from now on. The timer
+// never waits for a subscriber: once eventBuffer events are waiting, later
+// ones are dropped until the subscriber catches up, with a warning on
+// stderr. Call Unsubscribe when done with the channel.
+func (t *Timer) Events() <-chan Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sub := &subscriber{ch: make(chan Event, eventBuffer)}
+	t.subscribers = append(t.subscribers, sub)
+	return sub.ch
+}
+
+// Unsubscribe stops delivering events to ch, a channel returned by Events,
+// and closes it.
+func (t *Timer) Unsubscribe(ch <-chan Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, sub := range t.subscribers {
+		if sub.ch == ch {
+			t.subscribers = append(t.subscribers[:i], t.subscribers[i+1:]...)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// publish sends an event about the current phase to every subscriber with
+// room for it. Must be called with t.mu held.
+func (t *Timer) publish(typ EventType) {
+	if len(t.subscribers) == 0 {
+		return
+	}
+	event := Event{Type: typ, Phase: t.phase, Remaining: t.duration, At: time.Now()}
+	for _, sub := range t.subscribers {
+		select {
+		case sub.ch <- event:
+			sub.dropping = false
+		default:
+			// Slow subscriber; drop rather than stall the timer.
+			if !sub.dropping {
+				sub.dropping = true
+				fmt.Fprintf(os.Stderr, "Warning: event subscriber is %d events behind, dropping events\n", eventBuffer)
+			}
+		}
+	}
+}
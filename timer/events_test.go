@@ -0,0 +1,111 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	tm := New(time.Second, WithPhases(PhaseDurations{Work: time.Second}))
+	events := tm.Events()
+	defer tm.Unsubscribe(events)
+
+	tm.Start()
+	tm.Pause()
+	tm.Resume()
+
+	want := []struct {
+		typ   EventType
+		phase Phase
+	}{
+		{EventStarted, PhaseWork},
+		{EventPaused, PhaseWork},
+		{EventResumed, PhaseWork},
+		{EventCompleted, PhaseWork},
+		{EventPhaseChanged, ""}, // No breaks, so the timer goes idle
+	}
+	for _, w := range want {
+		select {
+		case event := <-events:
+			if event.Type != w.typ || event.Phase != w.phase {
+				t.Fatalf("got %s in %q, want %s in %q", event.Type, event.Phase, w.typ, w.phase)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %s", w.typ)
+		}
+	}
+}
+
+func TestEventsSlowSubscriber(t *testing.T) {
+	tm := New(10 * time.Minute)
+	events := tm.Events() // Never read
+	tm.Start()
+	defer tm.Pause()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*eventBuffer; i++ {
+			tm.Pause()
+			tm.Resume()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timer blocked on a slow subscriber")
+	}
+	if len(events) != eventBuffer {
+		t.Errorf("got %d buffered events, want %d", len(events), eventBuffer)
+	}
+
+	tm.Unsubscribe(events)
+	for range events {
+	}
+}
+
+func TestEventsStalledSubscriber(t *testing.T) {
+	tm := New(2*time.Second, WithPhases(PhaseDurations{Work: 2 * time.Second}))
+	stalled := tm.Events() // Never read
+	active := tm.Events()
+	defer tm.Unsubscribe(stalled)
+
+	received := make(chan int)
+	go func() {
+		n := 0
+		for event := range active {
+			n++
+			if event.Type == EventCompleted {
+				break
+			}
+		}
+		received <- n
+	}()
+
+	start := time.Now()
+	tm.Start()
+	// Overflow the stalled subscriber while the countdown runs.
+	for i := 0; i < eventBuffer; i++ {
+		tm.Pause()
+		tm.Resume()
+		time.Sleep(time.Millisecond) // Give the active subscriber a chance to keep up
+	}
+
+	select {
+	case n := <-received:
+		// Started, a pause and resume per round, at least one tick and completed.
+		if want := 2*eventBuffer + 3; n < want {
+			t.Errorf("active subscriber got %d events, want at least %d", n, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for completion with a stalled subscriber")
+	}
+	tm.Unsubscribe(active)
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("completion took %v with a stalled subscriber, want about 2s", elapsed)
+	}
+	if len(stalled) != eventBuffer {
+		t.Errorf("stalled subscriber holds %d events, want %d", len(stalled), eventBuffer)
+	}
+}
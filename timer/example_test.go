@@ -0,0 +1,43 @@
+package timer_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sakalys/pomidoras/timer"
+)
+
+// A timer can be driven directly, without a server.
+func Example() {
+	t := timer.New(25 * time.Minute)
+	t.AddSeconds(5 * 60)
+	fmt.Println(t.Status().State, t.Status().Duration)
+
+	t.Reset()
+	t.Pause()
+	fmt.Println(t.Status().State, t.Status().Duration)
+
+	t.Resume()
+	defer t.Pause()
+	fmt.Println(t.Status().State, t.Status().Phase)
+	// Output:
+	// countdown 30m0s
+	// paused 25m0s
+	// countdown work
+}
+
+func ExampleOnComplete() {
+	completed := make(chan timer.Phase, 1)
+	t := timer.New(time.Second,
+		timer.WithPhases(timer.PhaseDurations{Work: time.Second}), // No breaks
+		timer.OnComplete(func(phase timer.Phase) {
+			completed <- phase
+		}))
+	t.Start()
+
+	fmt.Println(<-completed, "completed")
+	fmt.Println("now", t.Status().State)
+	// Output:
+	// work completed
+	// now idle
+}
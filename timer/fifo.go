@@ -0,0 +1,46 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// WithCompletionFIFO writes a line to the named pipe at path whenever a
+// phase completes: the phase and the whole seconds it counted down, such as
+// "work 1500". Scripts read them with, say, "while read phase seconds".
+//
+// The timer never waits for a reader. The pipe is opened non-blocking for
+// each line, so with nobody reading, or a reader too slow to keep the pipe
+// from filling up, the line is dropped. A reader that wants every completion
+// should keep the pipe open rather than reopen it per line.
+func WithCompletionFIFO(path string) Option {
+	return func(t *Timer) {
+		t.completionFIFO = path
+	}
+}
+
+// writeCompletionFIFO writes the line for a completed phase to the
+// completion FIFO, if there is one, dropping it when nobody is reading. It
+// uses the raw descriptor: an os.File would hand it to the runtime poller,
+// which waits out EAGAIN rather than returning it when the pipe is full.
+func (t *Timer) writeCompletionFIFO(phase Phase, elapsed time.Duration) {
+	if t.completionFIFO == "" {
+		return
+	}
+	fd, err := syscall.Open(t.completionFIFO, syscall.O_WRONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if errors.Is(err, syscall.ENXIO) {
+		return // No reader
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening completion FIFO: %v\n", err)
+		return
+	}
+	defer syscall.Close(fd)
+	line := fmt.Sprintf("%s %d\n", phase, int(elapsed.Seconds()))
+	if _, err := syscall.Write(fd, []byte(line)); err != nil && !errors.Is(err, syscall.EAGAIN) {
+		fmt.Fprintf(os.Stderr, "Error writing completion FIFO: %v\n", err)
+	}
+}
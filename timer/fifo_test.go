@@ -0,0 +1,85 @@
+//go:build unix
+
+package timer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCompletionFIFO(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "completions")
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		t.Skip("cannot make a FIFO:", err)
+	}
+	complete := func() {
+		t.Helper()
+		done := make(chan struct{})
+		tm := New(time.Second,
+			WithPhases(PhaseDurations{Work: time.Second}),
+			WithCompletionFIFO(path),
+			OnComplete(func(Phase) { close(done) }))
+		tm.Start()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timer did not complete")
+		}
+	}
+
+	// Without a reader the line is dropped rather than blocking the timer.
+	complete()
+
+	reader, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	complete()
+	reader.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil || line != "work 1\n" {
+		t.Errorf("read %q (%v), want %q", line, err, "work 1\n")
+	}
+}
+
+func TestCompletionFIFOFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "completions")
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		t.Skip("cannot make a FIFO:", err)
+	}
+	// A reader that never reads, and a pipe already full.
+	reader, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(reader)
+	writer, err := syscall.Open(path, syscall.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(writer)
+	fill := make([]byte, 4096)
+	for {
+		if _, err := syscall.Write(writer, fill); err != nil {
+			break
+		}
+	}
+
+	done := make(chan struct{})
+	tm := New(time.Second,
+		WithPhases(PhaseDurations{Work: time.Second}),
+		WithCompletionFIFO(path),
+		OnComplete(func(Phase) { close(done) }))
+	tm.Start()
+	defer tm.Pause()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer waited for the full pipe")
+	}
+}
@@ -0,0 +1,79 @@
+package timer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Goal is the progress towards the daily goal of WithDailyGoal.
+type Goal struct {
+	Completed int `json:"completed"` // Work sessions completed since local midnight
+	Target    int `json:"target"`    // Zero without a goal
+	Percent   int `json:"percent"`   // Completed as a share of Target, rounded down
+}
+
+// GoalProgress counts the work sessions in records completed on now's day
+// towards target.
+func GoalProgress(records []HistoryRecord, target int, now time.Time) Goal {
+	today := midnight(now)
+	goal := Goal{Target: target}
+	for _, record := range records {
+		if isWork(record.Phase) && !record.Timestamp.Before(today) {
+			goal.Completed++
+		}
+	}
+	if target > 0 {
+		goal.Percent = goal.Completed * 100 / target
+	}
+	return goal
+}
+
+// WithDailyGoal sets a number of work sessions to complete each day, counted
+// from the history. Reaching it sends a congratulatory notification, once a
+// day, unless Notifications.Goal is off. Zero, the default, sets no goal.
+func WithDailyGoal(target int) Option {
+	return func(t *Timer) {
+		t.dailyGoal = target
+	}
+}
+
+// Goal reports today's progress towards the daily goal.
+func (t *Timer) Goal() (Goal, error) {
+	t.mu.RLock()
+	target := t.dailyGoal
+	t.mu.RUnlock()
+
+	now := time.Now()
+	records, err := t.HistoryRecordsSince(midnight(now))
+	if err != nil {
+		return Goal{}, err
+	}
+	return GoalProgress(records, target, now), nil
+}
+
+// goalReached returns the notification for reaching the daily goal with the
+// work session just recorded, if it has not been sent today. Must be called
+// with t.mu held.
+func (t *Timer) goalReached() []notification {
+	if t.dailyGoal <= 0 || t.history == nil || !t.notifications.Goal {
+		return nil
+	}
+	now := time.Now()
+	today := midnight(now)
+	if !t.goalNotified.Before(today) {
+		return nil
+	}
+	records, err := t.history.RecordsSince(today)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return nil
+	}
+	// Only the session that reaches the goal notifies, not later ones after
+	// a restart forgot goalNotified.
+	if GoalProgress(records, t.dailyGoal, now).Completed != t.dailyGoal {
+		return nil
+	}
+	t.goalNotified = today
+	return []notification{{PhaseWork, fmt.Sprintf("Daily goal reached: %d pomodoros today. Well done!", t.dailyGoal)}}
+}
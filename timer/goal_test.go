@@ -0,0 +1,61 @@
+package timer
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGoalProgress(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	records := []HistoryRecord{
+		{Timestamp: now.Add(-13 * time.Hour), Phase: PhaseWork}, // Yesterday
+		{Timestamp: now.Add(-3 * time.Hour), Phase: PhaseWork},
+		{Timestamp: now.Add(-2 * time.Hour), Phase: PhaseShortBreak},
+		{Timestamp: now.Add(-time.Hour)}, // Written before phases
+	}
+	if got, want := GoalProgress(records, 3, now), (Goal{Completed: 2, Target: 3, Percent: 66}); got != want {
+		t.Errorf("GoalProgress = %+v, want %+v", got, want)
+	}
+	if got, want := GoalProgress(records, 0, now), (Goal{Completed: 2}); got != want {
+		t.Errorf("GoalProgress without a target = %+v, want %+v", got, want)
+	}
+}
+
+func TestGoalNotification(t *testing.T) {
+	history := NewHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err := history.Append(HistoryRecord{Timestamp: time.Now(), Phase: PhaseWork, Duration: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var sent []string
+	done := make(chan struct{})
+	tm := New(time.Second,
+		WithPhases(PhaseDurations{Work: time.Second}),
+		WithHistory(history),
+		WithDailyGoal(2),
+		WithNotifier(func(_ Phase, _, message string) {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, message)
+		}),
+		OnComplete(func(Phase) { close(done) }))
+	tm.Start()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer did not complete")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"Work complete — take a break", "Daily goal reached: 2 pomodoros today. Well done!"}
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+	if goal, err := tm.Goal(); err != nil || goal != (Goal{Completed: 2, Target: 2, Percent: 100}) {
+		t.Errorf("Goal() = %+v, %v", goal, err)
+	}
+}
@@ -0,0 +1,328 @@
+package timer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryRecord is a single completed countdown, stored one per line as JSON.
+type HistoryRecord struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Phase     Phase         `json:"phase,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// historySchema identifies the header line of a history file.
+const historySchema = "pomidoras-history"
+
+// HistorySchemaVersion is the record format written to new history files.
+// Version 1 files predate the header and have no phase in their records.
+const HistorySchemaVersion = 2
+
+// HistoryHeader is the first line of a history file created by this version,
+// so other tools can tell the format apart as it evolves. Readers skip it.
+type HistoryHeader struct {
+	Schema  string            `json:"schema"`
+	Version int               `json:"version"`
+	Fields  map[string]string `json:"fields"` // Description of each record field
+}
+
+var historyHeader = HistoryHeader{
+	Schema:  historySchema,
+	Version: HistorySchemaVersion,
+	Fields: map[string]string{
+		"timestamp": "RFC 3339 time the phase completed",
+		"phase":     "work, short_break or long_break; missing means work",
+		"duration":  "time counted down in the phase, in nanoseconds",
+	},
+}
+
+// isHeader reports whether line is a HistoryHeader rather than a record.
+func isHeader(line []byte) bool {
+	var header struct {
+		Schema string `json:"schema"`
+	}
+	return json.Unmarshal(line, &header) == nil && header.Schema == historySchema
+}
+
+// History is an append-only JSON lines log of completed countdowns, kept in
+// one file or, see NewDailyHistory, in a file per day.
+type History struct {
+	path string // Single history file; empty for a daily history
+	dir  string // Directory of per-day files for a daily history
+	mu   sync.Mutex
+}
+
+func NewHistory(path string) *History {
+	return &History{path: path}
+}
+
+// NewDailyHistory keeps the history in dir, one file per local day named
+// like 2024-06-01.jsonl, so files stay small and old days can be pruned.
+// A single history file at dir+".jsonl", the layout used before daily files,
+// is read as the oldest part of the history.
+func NewDailyHistory(dir string) *History {
+	return &History{dir: dir}
+}
+
+// dayFormat names the files of a daily history.
+const dayFormat = "2006-01-02"
+
+// DefaultHistoryPath returns $XDG_DATA_HOME/pomidoras/history.jsonl, falling
+// back to ~/.local/share when XDG_DATA_HOME is unset.
+func DefaultHistoryPath() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "pomidoras", "history.jsonl")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "pomidoras-history.jsonl")
+	}
+	return filepath.Join(home, ".local", "share", "pomidoras", "history.jsonl")
+}
+
+// DefaultHistoryDir returns the directory for a daily history next to
+// DefaultHistoryPath, such as ~/.local/share/pomidoras/history.
+func DefaultHistoryDir() string {
+	return strings.TrimSuffix(DefaultHistoryPath(), ".jsonl")
+}
+
+// files returns the history files that may hold records from since on,
+// oldest first; a zero since means every file. A daily history skips the
+// files of earlier days, and the single file it started with unless since
+// is no later than the first day with a file. Must be called with h.mu held.
+func (h *History) files(since time.Time) ([]string, error) {
+	if h.dir == "" {
+		return []string{h.path}, nil
+	}
+	entries, err := os.ReadDir(h.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	from := midnight(since.Local())
+	var files []string
+	var first time.Time // The oldest day with a file
+
+	for _, entry := range entries { // Sorted by name, so by day
+		day, ok := fileDay(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if first.IsZero() {
+			first = day
+		}
+		if !day.Before(from) {
+			files = append(files, filepath.Join(h.dir, entry.Name()))
+		}
+	}
+	if first.IsZero() || !from.After(first) {
+		files = append([]string{h.dir + ".jsonl"}, files...)
+	}
+	return files, nil
+}
+
+// fileDay parses the day from the name of a daily history file.
+func fileDay(name string) (time.Time, bool) {
+	day, err := time.ParseInLocation(dayFormat+".jsonl", name, time.Local)
+	return day, err == nil
+}
+
+func (h *History) Append(record HistoryRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	path := h.path
+	if h.dir != "" {
+		path = filepath.Join(h.dir, record.Timestamp.Local().Format(dayFormat)+".jsonl")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		if err := encoder.Encode(historyHeader); err != nil {
+			return err
+		}
+	}
+	return encoder.Encode(record)
+}
+
+// Records returns every record in the history, oldest first. A missing
+// history file has no records. Files of any schema version are read, with or
+// without a header.
+func (h *History) Records() ([]HistoryRecord, error) {
+	return h.RecordsSince(time.Time{})
+}
+
+// RecordsSince returns the records completed at since or later, oldest
+// first, like Records. A daily history reads only the files that can hold
+// them, so older files cost nothing and errors in them do not matter.
+func (h *History) RecordsSince(since time.Time) ([]HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.files(since)
+	if err != nil {
+		return nil, err
+	}
+	var records []HistoryRecord
+	for _, path := range files {
+		if records, err = readRecords(path, records); err != nil {
+			break
+		}
+	}
+	records = slices.DeleteFunc(records, func(record HistoryRecord) bool { return record.Timestamp.Before(since) })
+	return records, err
+}
+
+// readRecords appends the records in the history file at path to records.
+func readRecords(path string, records []HistoryRecord) ([]HistoryRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return records, err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	for decoder.More() {
+		var line json.RawMessage
+		if err := decoder.Decode(&line); err != nil {
+			return records, err
+		}
+		if isHeader(line) {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// LastSession returns the most recent work session in the history. ok is
+// false if there is none. A daily history is read newest file first, up to
+// the file holding the session.
+func (h *History) LastSession() (record HistoryRecord, ok bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.files(time.Time{})
+	if err != nil {
+		return HistoryRecord{}, false, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		records, err := readRecords(files[i], nil)
+		for j := len(records) - 1; j >= 0; j-- {
+			if isWork(records[j].Phase) {
+				return records[j], true, err
+			}
+		}
+		if err != nil {
+			return HistoryRecord{}, false, err
+		}
+	}
+	return HistoryRecord{}, false, nil
+}
+
+// Clear empties the history and returns how many records it held. The files
+// of a daily history are removed.
+func (h *History) Clear() (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.files(time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, path := range files {
+		count, err := clearFile(path, h.dir != "")
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// clearFile truncates or removes the history file at path and returns how
+// many records it held.
+func clearFile(path string, remove bool) (int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 && !isHeader(line) {
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if remove {
+		return count, os.Remove(path)
+	}
+	return count, f.Truncate(0)
+}
+
+// Prune removes the files of a daily history for days more than days before
+// now's, returning how many were removed. A single-file history is left
+// alone.
+func (h *History) Prune(days int, now time.Time) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.dir == "" {
+		return 0, nil
+	}
+	cutoff := midnight(now.Local()).AddDate(0, 0, -days)
+	entries, err := os.ReadDir(h.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		day, ok := fileDay(entry.Name())
+		if !ok || entry.IsDir() || !day.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(h.dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,179 @@
+package timer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastSession(t *testing.T) {
+	h := NewHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if _, ok, err := h.LastSession(); ok || err != nil {
+		t.Fatalf("empty history: got ok %t, err %v, want neither", ok, err)
+	}
+
+	for _, record := range []HistoryRecord{
+		{Timestamp: time.Now(), Duration: 20 * time.Minute}, // Before phases were recorded
+		{Timestamp: time.Now(), Phase: PhaseWork, Duration: 50 * time.Minute},
+		{Timestamp: time.Now(), Phase: PhaseShortBreak, Duration: 10 * time.Minute},
+	} {
+		if err := h.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, ok, err := h.LastSession()
+	if !ok || err != nil || last.Duration != 50*time.Minute {
+		t.Errorf("got %+v (ok %t, err %v), want the 50m work session", last, ok, err)
+	}
+}
+
+func TestHistoryHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := NewHistory(path)
+	record := HistoryRecord{Timestamp: time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), Phase: PhaseWork, Duration: time.Minute}
+	for i := 0; i < 2; i++ {
+		if err := h.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !isHeader([]byte(lines[0])) {
+		t.Fatalf("got %q, want a header and two records", lines)
+	}
+	var header HistoryHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil || header.Version != HistorySchemaVersion {
+		t.Errorf("got header %+v (%v), want version %d", header, err, HistorySchemaVersion)
+	}
+
+	if records, err := h.Records(); err != nil || len(records) != 2 {
+		t.Errorf("got %d records (%v), want 2", len(records), err)
+	}
+	if removed, err := h.Clear(); err != nil || removed != 2 {
+		t.Errorf("Clear removed %d (%v), want 2", removed, err)
+	}
+}
+
+func TestHistoryVersion1(t *testing.T) {
+	// Written before the header and phases existed.
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	old := `{"timestamp":"2024-03-01T14:00:00Z","duration":1500000000000}` + "\n"
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := NewHistory(path).Records()
+	if err != nil || len(records) != 1 || records[0].Duration != 25*time.Minute || records[0].Phase != "" {
+		t.Errorf("got %+v (%v), want one 25m record without a phase", records, err)
+	}
+}
+
+func TestDailyHistory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	// Records from before daily files, next to the directory.
+	old := `{"timestamp":"2024-02-28T14:00:00Z","duration":1500000000000}` + "\n"
+	if err := os.WriteFile(dir+".jsonl", []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDailyHistory(dir)
+	march1 := time.Date(2024, 3, 1, 14, 0, 0, 0, time.Local)
+	for _, record := range []HistoryRecord{
+		{Timestamp: march1, Phase: PhaseWork, Duration: time.Minute},
+		{Timestamp: march1.Add(time.Hour), Phase: PhaseShortBreak, Duration: 2 * time.Minute},
+		{Timestamp: march1.AddDate(0, 0, 1), Phase: PhaseWork, Duration: 3 * time.Minute},
+	} {
+		if err := h.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"2024-03-01.jsonl", "2024-03-02.jsonl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("day file %s: %v", name, err)
+		}
+	}
+	records, err := h.Records()
+	if err != nil || len(records) != 4 {
+		t.Fatalf("got %+v (%v), want 4 records", records, err)
+	}
+	for i, want := range []time.Duration{25 * time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		if records[i].Duration != want {
+			t.Errorf("record %d lasted %v, want %v, oldest first", i, records[i].Duration, want)
+		}
+	}
+
+	if removed, err := h.Clear(); err != nil || removed != 4 {
+		t.Errorf("Clear removed %d (%v), want 4", removed, err)
+	}
+	if records, err := h.Records(); err != nil || len(records) != 0 {
+		t.Errorf("after Clear got %+v (%v), want none", records, err)
+	}
+}
+
+func TestRecordsSince(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	h := NewDailyHistory(dir)
+	march1 := time.Date(2024, 3, 1, 14, 0, 0, 0, time.Local)
+	for _, record := range []HistoryRecord{
+		{Timestamp: march1, Phase: PhaseWork, Duration: time.Minute},
+		{Timestamp: march1.AddDate(0, 0, 2), Phase: PhaseWork, Duration: 2 * time.Minute},
+		{Timestamp: march1.AddDate(0, 0, 2).Add(time.Hour), Phase: PhaseShortBreak, Duration: 3 * time.Minute},
+	} {
+		if err := h.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Corrupt files outside the range must not be read.
+	for _, path := range []string{dir + ".jsonl", filepath.Join(dir, "2024-03-02.jsonl")} {
+		if err := os.WriteFile(path, []byte("not json\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := h.RecordsSince(march1.AddDate(0, 0, 2))
+	if err != nil || len(records) != 2 || records[0].Duration != 2*time.Minute {
+		t.Errorf("got %+v (%v), want the 2 records of March 3", records, err)
+	}
+	if _, err := h.RecordsSince(march1); err == nil {
+		t.Error("reading the corrupt March 2 file: got no error")
+	}
+	last, ok, err := h.LastSession()
+	if !ok || err != nil || last.Duration != 2*time.Minute {
+		t.Errorf("got %+v (ok %t, err %v), want the 2m work session", last, ok, err)
+	}
+}
+
+func TestHistoryPrune(t *testing.T) {
+	dir := t.TempDir()
+	h := NewDailyHistory(dir)
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.Local)
+	for days := 0; days <= 5; days++ {
+		if err := h.Append(HistoryRecord{Timestamp: now.AddDate(0, 0, -days), Phase: PhaseWork, Duration: time.Minute}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644) // Not history, kept
+
+	removed, err := h.Prune(3, now)
+	if err != nil || removed != 2 {
+		t.Errorf("Prune removed %d (%v), want the 2 files more than 3 days old", removed, err)
+	}
+	if records, _ := h.Records(); len(records) != 4 {
+		t.Errorf("got %d records after pruning, want 4", len(records))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("pruning removed an unrelated file: %v", err)
+	}
+
+	if removed, err := NewHistory(filepath.Join(dir, "history.jsonl")).Prune(0, now); removed != 0 || err != nil {
+		t.Errorf("single-file Prune removed %d (%v), want nothing", removed, err)
+	}
+}
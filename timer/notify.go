@@ -0,0 +1,158 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Notifier delivers a notification about phase: that it has finished, or
+// a reminder that it is about to.
+type Notifier func(phase Phase, title, message string)
+
+// Notifications chooses which events notify.
+type Notifications struct {
+	Reminders  bool // Reminders before a work session ends, see WithReminders
+	Completion bool // A phase finishing
+	Goal       bool // Reaching the daily goal, see WithDailyGoal
+}
+
+var DefaultNotifications = Notifications{Reminders: true, Completion: true, Goal: true}
+
+// Messages holds the notification body templates for completed phases.
+// {phase} and {next_phase} are replaced with readable phase names, and the
+// next phase reads "idle" when the cycle ends.
+type Messages struct {
+	Work  string // Sent when a work session completes
+	Break string // Sent when a short or long break completes
+}
+
+var DefaultMessages = Messages{
+	Work:  "Work complete — take a break",
+	Break: "Break over — back to work",
+}
+
+// Format returns the body for completed, followed by next.
+func (m Messages) Format(completed, next Phase) string {
+	message := m.Work
+	if completed == PhaseShortBreak || completed == PhaseLongBreak {
+		message = m.Break
+	}
+	return strings.NewReplacer(
+		"{phase}", phaseLabel(completed),
+		"{next_phase}", phaseLabel(next),
+	).Replace(message)
+}
+
+func phaseLabel(p Phase) string {
+	switch p {
+	case "":
+		return "idle"
+	case PhaseShortBreak:
+		return "short break"
+	case PhaseLongBreak:
+		return "long break"
+	}
+	return string(p)
+}
+
+// Urgency levels understood by notify-send.
+const (
+	UrgencyLow      = "low"
+	UrgencyNormal   = "normal"
+	UrgencyCritical = "critical"
+)
+
+// ParseUrgency validates a notify-send urgency level.
+func ParseUrgency(s string) (string, error) {
+	switch s {
+	case UrgencyLow, UrgencyNormal, UrgencyCritical:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown urgency %q, want low, normal or critical", s)
+}
+
+// Bell returns a Notifier that rings the terminal bell by writing BEL to f.
+// It does nothing unless f is a terminal.
+func Bell(f *os.File) Notifier {
+	tty := term.IsTerminal(int(f.Fd()))
+	return func(phase Phase, title, message string) {
+		if tty {
+			fmt.Fprint(f, "\a")
+		}
+	}
+}
+
+// Notifiers returns a Notifier that calls each of ns in order, skipping nil
+// ones.
+func Notifiers(ns ...Notifier) Notifier {
+	return func(phase Phase, title, message string) {
+		for _, n := range ns {
+			if n != nil {
+				n(phase, title, message)
+			}
+		}
+	}
+}
+
+// NotifySendConfig controls how NotifySend builds the notify-send command.
+type NotifySendConfig struct {
+	Command      string        // notify-send compatible command; defaults to notify-send
+	WorkUrgency  string        // Urgency when a work session completes
+	BreakUrgency string        // Urgency when a break completes
+	Timeout      time.Duration // Passed as -t; 0 leaves expiry to the notification daemon
+	KillAfter    time.Duration // How long the command may run; defaults to defaultNotifyKillAfter
+}
+
+// defaultNotifyKillAfter is how long notify-send may take before it is
+// killed. It normally returns at once, but blocks while the notification
+// daemon hangs, and every completion would leave another process behind.
+const defaultNotifyKillAfter = 5 * time.Second
+
+var DefaultNotifySendConfig = NotifySendConfig{
+	Command:      "notify-send",
+	WorkUrgency:  UrgencyCritical,
+	BreakUrgency: UrgencyCritical,
+}
+
+// NotifySend returns a Notifier that sends desktop notifications using
+// notify-send.
+func NotifySend(config NotifySendConfig) Notifier {
+	return func(phase Phase, title, message string) {
+		urgency := config.WorkUrgency
+		if phase == PhaseShortBreak || phase == PhaseLongBreak {
+			urgency = config.BreakUrgency
+		}
+
+		args := []string{"-u", urgency}
+		if config.Timeout > 0 {
+			args = append(args, "-t", strconv.FormatInt(config.Timeout.Milliseconds(), 10))
+		}
+		args = append(args, title, message)
+
+		command := config.Command
+		if command == "" {
+			command = "notify-send"
+		}
+		killAfter := config.KillAfter
+		if killAfter <= 0 {
+			killAfter = defaultNotifyKillAfter
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), killAfter)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, command, args...)
+		err := cmd.Run()
+		if ctx.Err() == context.DeadlineExceeded {
+			fmt.Fprintf(os.Stderr, "Warning: %s did not finish within %v, killed it\n", command, killAfter)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error sending notification: %v\n", err)
+			// Consider logging the error to a file
+		}
+	}
+}
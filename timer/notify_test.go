@@ -0,0 +1,77 @@
+package timer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessagesFormat(t *testing.T) {
+	custom := Messages{
+		Work:  "{phase} done, {next_phase} next",
+		Break: "{phase} over, {next_phase} now",
+	}
+	tests := []struct {
+		messages  Messages
+		completed Phase
+		next      Phase
+		want      string
+	}{
+		{DefaultMessages, PhaseWork, PhaseShortBreak, "Work complete — take a break"},
+		{DefaultMessages, PhaseLongBreak, "", "Break over — back to work"},
+		{custom, PhaseWork, PhaseLongBreak, "work done, long break next"},
+		{custom, PhaseWork, "", "work done, idle next"},
+		{custom, PhaseShortBreak, "", "short break over, idle now"},
+	}
+	for _, tt := range tests {
+		if got := tt.messages.Format(tt.completed, tt.next); got != tt.want {
+			t.Errorf("Format(%q, %q) = %q, want %q", tt.completed, tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var got []string
+	record := func(name string) Notifier {
+		return func(phase Phase, title, message string) {
+			got = append(got, name+": "+message)
+		}
+	}
+
+	Notifiers(record("a"), nil, record("b"))(PhaseWork, "Pomidoras", "done")
+	if want := "a: done|b: done"; strings.Join(got, "|") != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBellNotTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	Bell(f)(PhaseWork, "Pomidoras", "done")
+	if info, err := f.Stat(); err != nil || info.Size() != 0 {
+		t.Errorf("wrote %d bytes to a regular file, want none", info.Size())
+	}
+}
+
+func TestNotifySendHung(t *testing.T) {
+	// A notify-send stuck on a hung notification daemon.
+	command := filepath.Join(t.TempDir(), "notify-send")
+	if err := os.WriteFile(command, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultNotifySendConfig
+	config.Command = command
+	config.KillAfter = 100 * time.Millisecond
+
+	start := time.Now()
+	NotifySend(config)(PhaseWork, "Pomidoras", "done")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("notifier returned after %v, want the command killed after 100ms", elapsed)
+	}
+}
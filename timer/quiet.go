@@ -0,0 +1,92 @@
+package timer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietWindow is a daily stretch of local time without notifications or
+// sounds. Start and End are offsets from midnight. A window whose End is
+// before its Start wraps past midnight.
+type QuietWindow struct {
+	Start, End time.Duration
+}
+
+// Contains reports whether the local time of now falls in the window.
+func (w QuietWindow) Contains(now time.Time) bool {
+	offset := now.Sub(midnight(now))
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// ParseQuietHours parses a comma separated list of HH:MM-HH:MM windows, such
+// as "09:00-10:00,22:00-07:00".
+func ParseQuietHours(s string) ([]QuietWindow, error) {
+	var windows []QuietWindow
+	for _, field := range strings.Split(s, ",") {
+		start, end, ok := strings.Cut(strings.TrimSpace(field), "-")
+		if !ok {
+			return nil, fmt.Errorf("%q is not HH:MM-HH:MM", field)
+		}
+		var w QuietWindow
+		var err error
+		if w.Start, err = parseClock(start); err != nil {
+			return nil, err
+		}
+		if w.End, err = parseClock(end); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseClock turns HH:MM into an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// WithQuietHours suppresses notifications and sounds during the windows.
+// Phases still complete and are recorded as usual.
+func WithQuietHours(windows ...QuietWindow) Option {
+	return func(t *Timer) {
+		t.quietHours = windows
+	}
+}
+
+// ToggleMute mutes notifications and sounds until called again, like quiet
+// hours that last until unmuted, and reports whether they are muted now.
+func (t *Timer) ToggleMute() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.muted = !t.muted
+	return t.muted
+}
+
+// Muted reports whether ToggleMute muted notifications and sounds.
+func (t *Timer) Muted() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.muted
+}
+
+// quiet reports whether now falls in any quiet window or the timer is muted.
+// Must be called without t.mu held.
+func (t *Timer) quiet(now time.Time) bool {
+	if t.Muted() {
+		return true
+	}
+	for _, w := range t.quietHours {
+		if w.Contains(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHours(t *testing.T) {
+	windows, err := ParseQuietHours("09:00-10:00, 22:30-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := New(0, WithQuietHours(windows...))
+
+	tests := []struct {
+		clock string
+		quiet bool
+	}{
+		{"08:59", false},
+		{"09:00", true},
+		{"09:59", true},
+		{"10:00", false},
+		{"22:29", false},
+		{"23:45", true},
+		{"00:00", true},
+		{"06:59", true},
+		{"07:00", false},
+	}
+	for _, tt := range tests {
+		clock, _ := time.Parse("15:04", tt.clock)
+		now := time.Date(2024, 3, 1, clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		if got := tm.quiet(now); got != tt.quiet {
+			t.Errorf("%s: got quiet %t, want %t", tt.clock, got, tt.quiet)
+		}
+	}
+
+	for _, invalid := range []string{"09:00", "9-10", "09:00-25:00"} {
+		if _, err := ParseQuietHours(invalid); err == nil {
+			t.Errorf("%q: got no error", invalid)
+		}
+	}
+}
+
+func TestQuietHoursSkipNotifications(t *testing.T) {
+	sent := false
+	allDay := QuietWindow{Start: 0, End: 24 * time.Hour}
+	tm := New(0,
+		WithQuietHours(allDay),
+		WithNotifier(func(phase Phase, title, message string) { sent = true }))
+	tm.sendNotifications([]notification{{PhaseWork, "Work complete"}})
+	if sent {
+		t.Error("notification sent during quiet hours")
+	}
+}
+
+func TestToggleMute(t *testing.T) {
+	var sent []string
+	tm := New(0, WithNotifier(func(phase Phase, title, message string) { sent = append(sent, message) }))
+	if !tm.ToggleMute() || !tm.Muted() {
+		t.Fatal("not muted by the first toggle")
+	}
+	tm.sendNotifications([]notification{{PhaseWork, "muted"}})
+	if tm.ToggleMute() || tm.Muted() {
+		t.Fatal("still muted after the second toggle")
+	}
+	tm.sendNotifications([]notification{{PhaseWork, "unmuted"}})
+	if len(sent) != 1 || sent[0] != "unmuted" {
+		t.Errorf("sent %q, want only the unmuted notification", sent)
+	}
+}
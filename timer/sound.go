@@ -0,0 +1,55 @@
+package timer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Sounds configures the sound played when a phase completes. An empty file
+// plays nothing.
+type Sounds struct {
+	Work   string // Played when a work session completes
+	Break  string // Played when a short or long break completes
+	Player string // Command run with the file as its argument; defaults to paplay
+}
+
+// file returns the sound for completed.
+func (s Sounds) file(completed Phase) string {
+	if completed == PhaseShortBreak || completed == PhaseLongBreak {
+		return s.Break
+	}
+	return s.Work
+}
+
+// WithSounds plays a sound whenever a phase completes.
+func WithSounds(s Sounds) Option {
+	return func(t *Timer) {
+		t.sounds = s
+	}
+}
+
+// playSound starts the sound for completed without waiting for it to finish.
+// A missing file is reported and skipped. Nothing plays during quiet hours.
+func (t *Timer) playSound(completed Phase) {
+	file := t.sounds.file(completed)
+	if file == "" || t.quiet(time.Now()) {
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping sound: %v\n", err)
+		return
+	}
+
+	player := t.sounds.Player
+	if player == "" {
+		player = "paplay"
+	}
+	cmd := exec.Command(player, file)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error playing sound: %v\n", err)
+		return
+	}
+	go cmd.Wait() // Reap the player
+}
@@ -0,0 +1,53 @@
+package timer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSoundsFile(t *testing.T) {
+	s := Sounds{Work: "work.ogg", Break: "break.ogg"}
+	for phase, want := range map[Phase]string{
+		PhaseWork:       "work.ogg",
+		PhaseShortBreak: "break.ogg",
+		PhaseLongBreak:  "break.ogg",
+	} {
+		if got := s.file(phase); got != want {
+			t.Errorf("%s: got %q, want %q", phase, got, want)
+		}
+	}
+}
+
+func TestPlaySound(t *testing.T) {
+	dir := t.TempDir()
+	played := filepath.Join(dir, "played")
+	sound := filepath.Join(dir, "break.ogg")
+	if err := os.WriteFile(sound, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The player records which file it was given.
+	player := filepath.Join(dir, "player")
+	script := "#!/bin/sh\necho \"$1\" > " + played + "\n"
+	if err := os.WriteFile(player, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tm := New(0, WithSounds(Sounds{Work: filepath.Join(dir, "missing.ogg"), Break: sound, Player: player}))
+	tm.playSound(PhaseWork) // Missing: warns and skips
+	tm.playSound(PhaseShortBreak)
+
+	var got []byte
+	var err error
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if got, err = os.ReadFile(played); len(got) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if string(got) != sound+"\n" {
+		t.Errorf("played %q (%v), want %s", got, err, sound)
+	}
+}
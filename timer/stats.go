@@ -0,0 +1,50 @@
+package timer
+
+import "time"
+
+// DayTotal is the work completed on one local day, see DailyTotals.
+type DayTotal struct {
+	Date     time.Time     `json:"date"` // Local midnight starting the day
+	Sessions int           `json:"sessions"`
+	Focused  time.Duration `json:"focused"`
+}
+
+// DailyTotals adds up the work sessions in records for each of the days
+// calendar days ending with now's, oldest first. Days without any work are
+// included with zero totals, and today only counts so far. As in Streaks,
+// days are in now's location.
+func DailyTotals(records []HistoryRecord, days int, now time.Time) []DayTotal {
+	if days <= 0 {
+		return nil
+	}
+	totals := make([]DayTotal, days)
+	first := midnight(now).AddDate(0, 0, 1-days)
+	index := make(map[time.Time]int, days)
+	for i := range totals {
+		totals[i].Date = first.AddDate(0, 0, i)
+		index[totals[i].Date] = i
+	}
+	for _, record := range records {
+		if !isWork(record.Phase) {
+			continue
+		}
+		i, ok := index[midnight(record.Timestamp.In(now.Location()))]
+		if !ok {
+			continue
+		}
+		totals[i].Sessions++
+		totals[i].Focused += record.Duration
+	}
+	return totals
+}
+
+// Stats returns the DailyTotals of the last days calendar days, reading only
+// those days of the history.
+func (t *Timer) Stats(days int) ([]DayTotal, error) {
+	now := time.Now()
+	records, err := t.HistoryRecordsSince(midnight(now).AddDate(0, 0, 1-days))
+	if err != nil {
+		return nil, err
+	}
+	return DailyTotals(records, days, now), nil
+}
@@ -0,0 +1,45 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyTotals(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Vilnius")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 3, day, hour, 30, 0, 0, loc)
+	}
+	records := []HistoryRecord{
+		{Timestamp: at(3, 12).UTC(), Phase: PhaseWork, Duration: 25 * time.Minute}, // Before the range
+		{Timestamp: at(4, 9).UTC(), Phase: PhaseWork, Duration: 25 * time.Minute},
+		{Timestamp: at(4, 23).UTC(), Duration: 50 * time.Minute}, // Written before phases
+		{Timestamp: at(4, 23).UTC(), Phase: PhaseShortBreak, Duration: 5 * time.Minute},
+		{Timestamp: at(10, 8).UTC(), Phase: PhaseWork, Duration: 30 * time.Minute},
+	}
+
+	totals := DailyTotals(records, 7, at(10, 12))
+	if len(totals) != 7 {
+		t.Fatalf("got %d days, want 7", len(totals))
+	}
+	want := map[int]DayTotal{
+		0: {Sessions: 2, Focused: 75 * time.Minute},
+		6: {Sessions: 1, Focused: 30 * time.Minute},
+	}
+	for i, total := range totals {
+		if !total.Date.Equal(time.Date(2024, 3, 4+i, 0, 0, 0, 0, loc)) {
+			t.Errorf("day %d is %v", i, total.Date)
+		}
+		w := want[i] // Zero totals for days without work
+		if total.Sessions != w.Sessions || total.Focused != w.Focused {
+			t.Errorf("day %d: got %d sessions, %v focused, want %d, %v", i, total.Sessions, total.Focused, w.Sessions, w.Focused)
+		}
+	}
+
+	if totals := DailyTotals(records, 0, at(10, 12)); totals != nil {
+		t.Errorf("zero days: got %v", totals)
+	}
+}
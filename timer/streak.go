@@ -0,0 +1,52 @@
+package timer
+
+import (
+	"sort"
+	"time"
+)
+
+// Streak counts consecutive days with at least one completed work session.
+type Streak struct {
+	Current int `json:"current"` // Ending today, or yesterday while today has none yet
+	Longest int `json:"longest"`
+}
+
+// Streaks computes the streaks in records as of now. Days are calendar days
+// in now's location, so a session just before midnight counts for the day it
+// ended on, and days are stepped by date rather than by 24 hours so
+// daylight saving changes do not split or merge them.
+func Streaks(records []HistoryRecord, now time.Time) Streak {
+	seen := make(map[time.Time]bool)
+	var days []time.Time
+	for _, record := range records {
+		if !isWork(record.Phase) {
+			continue
+		}
+		day := midnight(record.Timestamp.In(now.Location()))
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var streak Streak
+	run := 0
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		streak.Longest = max(streak.Longest, run)
+	}
+
+	today := midnight(now)
+	if !seen[today] {
+		today = today.AddDate(0, 0, -1) // Today can still continue yesterday's streak
+	}
+	for day := today; seen[day]; day = day.AddDate(0, 0, -1) {
+		streak.Current++
+	}
+	return streak
+}
@@ -0,0 +1,46 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreaks(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Vilnius")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 30, 0, 0, loc)
+	}
+	work := func(times ...time.Time) []HistoryRecord {
+		var records []HistoryRecord
+		for _, at := range times {
+			records = append(records, HistoryRecord{Timestamp: at.UTC(), Phase: PhaseWork, Duration: 25 * time.Minute})
+		}
+		return records
+	}
+
+	tests := []struct {
+		name    string
+		records []HistoryRecord
+		now     time.Time
+		want    Streak
+	}{
+		{"empty", nil, at(3, 10, 12), Streak{}},
+		{"today", work(at(3, 10, 9)), at(3, 10, 12), Streak{Current: 1, Longest: 1}},
+		{"yesterday counts until today ends", work(at(3, 8, 9), at(3, 9, 9)), at(3, 10, 12), Streak{Current: 2, Longest: 2}},
+		{"gap ends the streak", work(at(3, 5, 9), at(3, 6, 9), at(3, 7, 9), at(3, 9, 9)), at(3, 11, 12), Streak{Current: 0, Longest: 3}},
+		// 23:30 local is already the next day in UTC; the local date counts.
+		{"late evening", work(at(3, 9, 23), at(3, 10, 0)), at(3, 10, 12), Streak{Current: 2, Longest: 2}},
+		// The clocks go forward on March 31, a 23 hour day.
+		{"daylight saving", work(at(3, 30, 9), at(3, 31, 9), at(4, 1, 9)), at(4, 1, 12), Streak{Current: 3, Longest: 3}},
+		{"several sessions a day", work(at(3, 9, 9), at(3, 9, 14), at(3, 10, 9)), at(3, 10, 12), Streak{Current: 2, Longest: 2}},
+		{"breaks do not count", []HistoryRecord{{Timestamp: at(3, 9, 9), Phase: PhaseShortBreak}}, at(3, 9, 12), Streak{}},
+	}
+	for _, tt := range tests {
+		if got := Streaks(tt.records, tt.now); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
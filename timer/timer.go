@@ -0,0 +1,1209 @@
+// Package timer implements the pomodoro countdown shared by the pomidoras
+// binaries. Display and notification behavior are injected with options.
+//
+// The package has no networking of its own and can be embedded directly,
+// for example in a TUI: create a Timer with New, drive it with Start, Pause,
+// Resume, AddSeconds and Reset, read it with Status, and follow it with
+// Events, OnComplete or Changed. All methods are safe for concurrent use.
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+type State string
+
+const (
+	StateCountdown State = "countdown"
+	StateIdle      State = "idle"
+	StatePaused    State = "paused"
+	StateScheduled State = "scheduled" // Waiting for a scheduled work session to begin
+
+	// StateStartingBreak waits out PhaseDurations.BreakStartDelay between a
+	// completed work session and its break. Phase and Duration already
+	// describe the break.
+	StateStartingBreak State = "starting_break"
+)
+
+type Phase string
+
+const (
+	PhaseWork       Phase = "work"
+	PhaseShortBreak Phase = "short_break"
+	PhaseLongBreak  Phase = "long_break"
+)
+
+// PhaseDurations configures the work/break cycle. A zero break duration
+// disables that break, and a zero LongBreakInterval disables long breaks.
+type PhaseDurations struct {
+	Work              time.Duration `json:"work"`
+	ShortBreak        time.Duration `json:"short_break"`
+	LongBreak         time.Duration `json:"long_break"`
+	LongBreakInterval int           `json:"long_break_interval"` // Number of work sessions between long breaks
+
+	// BreakStartDelay is a grace period, in StateStartingBreak, between the
+	// end of a work session and the start of its break, time to get up
+	// before the break counts down. Zero starts breaks right away.
+	BreakStartDelay time.Duration `json:"break_start_delay"`
+}
+
+var DefaultPhaseDurations = PhaseDurations{
+	Work:              25 * time.Minute,
+	ShortBreak:        5 * time.Minute,
+	LongBreak:         15 * time.Minute,
+	LongBreakInterval: 4,
+}
+
+type Timer struct {
+	duration        time.Duration
+	initialDuration time.Duration
+	nextWork        time.Duration // One-shot length of the next work session, see SetNextWork
+	state           State
+	ticker          *time.Ticker
+	done            chan struct{} // Closed to stop the run goroutine of ticker
+	mu              sync.RWMutex
+	terminalWidth   int
+	output          io.Writer // Countdown display; nil keeps the timer silent
+	tickLog         io.Writer // See WithTickLog
+	completionFIFO  string    // See WithCompletionFIFO
+	flashFor        time.Duration
+	flashUntil      time.Time // When the flash of the last completion ends, see WithFlash
+	inPlace         bool      // Redraw one line with \r; false writes a line per tick
+	notify          Notifier  // nil disables notifications
+	onComplete      func(completed Phase)
+	sounds          Sounds
+	dnd             DND
+	dndQueue        chan string // nil without DND commands, see WithDND
+	dndOn           bool        // Whether On was the last command queued
+	activeFile      string      // See WithActiveFile
+	activeFileOn    bool        // Whether activeFile was last created rather than removed
+	quietHours      []QuietWindow
+	muted           bool // See ToggleMute
+	messages        Messages
+	notifications   Notifications
+	reminderTimes   []time.Duration // Remaining times in a work session that trigger a reminder
+	reminderCommand string          // See WithReminderCommand
+	finalWarning    time.Duration   // See WithFinalWarning
+
+	phase              Phase
+	phases             PhaseDurations
+	elapsed            time.Duration // Time counted down in the current phase
+	pauseReason        string        // Why the timer is paused, see PauseWithReason
+	completedPomodoros int
+	resetKeepsCount    bool          // See WithResetPreservesCount
+	minRemaining       time.Duration // See WithMinRemaining
+	minNotify          time.Duration // See WithMinNotifyDuration
+	focused            time.Duration // Work time completed since focusedSince
+	focusedSince       time.Time     // Local midnight the focused total started at
+	dailyGoal          int           // See WithDailyGoal
+	goalNotified       time.Time     // Local midnight of the day the goal was last reached
+	history            *History      // nil disables history recording
+
+	startedAt  time.Time   // When the current countdown began; zero when idle
+	startsAt   time.Time   // When a scheduled work session or a delayed break begins
+	startTimer *time.Timer // Fires at startsAt; nil unless scheduled or starting a break
+
+	changed     chan struct{} // Closed and replaced whenever the status changes
+	subscribers []*subscriber // See Events
+}
+
+type Status struct {
+	State        State         `json:"state"`
+	Duration     time.Duration `json:"duration"`
+	Phase        Phase         `json:"phase,omitempty"`
+	FocusedToday time.Duration `json:"focused_today,omitempty"` // Completed work time since local midnight
+	StartsIn     time.Duration `json:"starts_in,omitempty"`     // Time until a scheduled start or a delayed break, see ScheduleStart
+	StartedAt    time.Time     `json:"started_at"`              // When the current phase started counting down; zero when idle
+	Flash        bool          `json:"flash,omitempty"`         // A phase just completed, see WithFlash
+	PauseReason  string        `json:"pause_reason,omitempty"`  // Why the timer is paused, see PauseWithReason
+
+	// InitialDuration is the full length of the current phase, time already
+	// counted down plus Duration, so it includes any time added since the
+	// phase started. Zero when idle.
+	InitialDuration time.Duration `json:"initial_duration,omitempty"`
+
+	// CycleRemaining is the time left until the current work session and
+	// the break after it have both run, or until the end of the running or
+	// starting break. It assumes the break runs its configured length and a
+	// paused timer is resumed right away. Zero when idle or scheduled.
+	CycleRemaining time.Duration `json:"cycle_remaining,omitempty"`
+}
+
+// LongBreakEstimate describes when the next long break starts.
+type LongBreakEstimate struct {
+	Enabled  bool          `json:"enabled"`
+	Sessions int           `json:"sessions"` // Work sessions to complete first, including a running one
+	In       time.Duration `json:"in"`
+	At       time.Time     `json:"at"`
+}
+
+// ReminderEstimate describes the next reminder of a work session.
+type ReminderEstimate struct {
+	Left time.Duration `json:"left"` // Remaining time that triggers it, see WithReminders
+	In   time.Duration `json:"in"`
+	At   time.Time     `json:"at"`
+}
+
+// Option configures a Timer created with New.
+type Option func(*Timer)
+
+// WithPhases sets the work/break cycle. Defaults to DefaultPhaseDurations.
+func WithPhases(phases PhaseDurations) Option {
+	return func(t *Timer) {
+		t.phases = phases
+	}
+}
+
+// WithHistory records every completed phase in h.
+func WithHistory(h *History) Option {
+	return func(t *Timer) {
+		t.history = h
+	}
+}
+
+// WithOutput makes the timer show its countdown on w every tick. On a
+// terminal the line is redrawn in place and each phase ends with a newline;
+// anywhere else (pipes, log files) every tick gets a line of its own.
+// Without it the timer is silent.
+func WithOutput(w io.Writer) Option {
+	return func(t *Timer) {
+		t.output = w
+		t.terminalWidth = 80 // Default width if we can't get the size
+		t.inPlace = false
+		if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+			t.inPlace = true
+			if width, _, err := term.GetSize(int(f.Fd())); err == nil {
+				t.terminalWidth = width
+			}
+		}
+	}
+}
+
+// WithTickLog writes a timestamped line to w whenever the displayed minute
+// of a countdown changes, such as
+//
+//	2026-10-16T09:35:00+03:00 work 24:59
+//
+// for auditing how a session actually ran. Pauses show up as gaps between
+// the timestamps. Without it nothing is logged.
+func WithTickLog(w io.Writer) Option {
+	return func(t *Timer) {
+		t.tickLog = w
+	}
+}
+
+// WithFlash sets Status.Flash for d after each phase completes, a hint for
+// status bars to draw attention to the change. Changed fires again when the
+// flash ends. Zero, the default, never flashes.
+func WithFlash(d time.Duration) Option {
+	return func(t *Timer) {
+		t.flashFor = d
+	}
+}
+
+// WithNotifier calls n whenever a phase completes.
+func WithNotifier(n Notifier) Option {
+	return func(t *Timer) {
+		t.notify = n
+	}
+}
+
+// WithMessages sets the notification bodies. Defaults to DefaultMessages.
+func WithMessages(m Messages) Option {
+	return func(t *Timer) {
+		t.messages = m
+	}
+}
+
+// OnComplete calls f after each phase completes, once the timer has moved
+// on to the next phase or gone idle. f runs on the timer's goroutine, so it
+// should return quickly.
+func OnComplete(f func(completed Phase)) Option {
+	return func(t *Timer) {
+		t.onComplete = f
+	}
+}
+
+// WithNotifications chooses which events notify. Defaults to
+// DefaultNotifications.
+func WithNotifications(n Notifications) Option {
+	return func(t *Timer) {
+		t.notifications = n
+	}
+}
+
+// WithReminders sends a reminder whenever a work session has one of before
+// left, such as 10 minutes.
+func WithReminders(before ...time.Duration) Option {
+	return func(t *Timer) {
+		t.reminderTimes = before
+	}
+}
+
+// WithReminderCommand runs command with sh -c at every reminder of
+// WithReminders, with the remaining whole seconds as its last argument, such
+// as "dim-lights" run as dim-lights 300. It runs whether or not reminders
+// notify, without waiting for it, and failures are logged.
+func WithReminderCommand(command string) Option {
+	return func(t *Timer) {
+		t.reminderCommand = command
+	}
+}
+
+// WithFinalWarning sends a single warning when any phase has lead left,
+// such as "30s left", whichever reminders WithReminders sets and whether or
+// not they notify. Like reminders it fires as the remaining time drops to
+// lead, so resetting or adding time past it arms it again. Zero, the
+// default, sends none.
+func WithFinalWarning(lead time.Duration) Option {
+	return func(t *Timer) {
+		t.finalWarning = lead
+	}
+}
+
+// WithResetPreservesCount chooses whether Reset keeps the count of completed
+// work sessions, which places long breaks. Kept, the default, a reset only
+// abandons the running phase and long breaks stay where they were due; not
+// kept, the reset session starts a new cycle of LongBreakInterval sessions.
+func WithResetPreservesCount(preserve bool) Option {
+	return func(t *Timer) {
+		t.resetKeepsCount = preserve
+	}
+}
+
+// WithMinRemaining refuses subtractions that would leave a running phase
+// with less than floor, so a stray script cannot end a session by accident.
+// Zero, the default, allows any subtraction.
+func WithMinRemaining(floor time.Duration) Option {
+	return func(t *Timer) {
+		t.minRemaining = floor
+	}
+}
+
+// WithMinNotifyDuration skips the completion notification of phases that
+// counted down for less than min, such as a tiny test timer, logging each one
+// skipped. Zero, the default, notifies for every phase.
+func WithMinNotifyDuration(min time.Duration) Option {
+	return func(t *Timer) {
+		t.minNotify = min
+	}
+}
+
+// New creates a timer counting down initialDuration as a work session.
+// A zero initialDuration leaves the timer idle, and resets then start a work
+// session of the configured work duration.
+func New(initialDuration time.Duration, opts ...Option) *Timer {
+	t := &Timer{
+		duration: initialDuration,
+		state:    StateIdle,
+		phases:   DefaultPhaseDurations,
+		messages: DefaultMessages,
+		changed:  make(chan struct{}),
+
+		notifications:   DefaultNotifications,
+		resetKeepsCount: true,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if initialDuration > 0 {
+		t.state = StateCountdown
+		t.phase = PhaseWork
+		t.initialDuration = initialDuration
+	} else {
+		t.initialDuration = t.phases.Work
+	}
+	t.loadFocused()
+	return t
+}
+
+// loadFocused seeds today's focused total from the history, so restarting the
+// server neither loses nor double counts completed work.
+func (t *Timer) loadFocused() {
+	t.focusedSince = midnight(time.Now())
+	if t.history == nil {
+		return
+	}
+	records, err := t.history.RecordsSince(t.focusedSince)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+	}
+	for _, record := range records {
+		if isWork(record.Phase) && !record.Timestamp.Before(t.focusedSince) {
+			t.focused += record.Duration
+		}
+	}
+}
+
+// midnight returns the start of the local day containing now.
+func midnight(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+}
+
+// isWork reports whether a history record phase is a work session. Records
+// written before phases existed have no phase and were all work.
+func isWork(phase Phase) bool {
+	return phase == PhaseWork || phase == ""
+}
+
+// Start begins counting down the duration passed to New, or leaves the timer
+// idle if it was zero.
+func (t *Timer) Start() {
+	if t.duration > 0 {
+		t.mu.Lock()
+		t.state = StateCountdown
+		t.startedAt = time.Now()
+		t.startTicker()
+		t.render()
+		t.publish(EventStarted)
+		t.signalChange()
+		t.mu.Unlock()
+	} else {
+		t.mu.Lock()
+		t.state = StateIdle
+		t.startedAt = time.Time{}
+		t.signalChange()
+		t.mu.Unlock()
+	}
+}
+
+// startTicker starts ticking once a second with a run goroutine of its own,
+// stopping any previous ticker and goroutine. Must be called with t.mu held.
+func (t *Timer) startTicker() {
+	t.stopTicker()
+	t.ticker = time.NewTicker(1 * time.Second)
+	t.done = make(chan struct{})
+	go t.run(t.ticker.C, t.done)
+}
+
+// stopTicker stops the ticker and tells its run goroutine to exit. Stopping
+// a ticker does not close its channel, so without done the goroutine would
+// wait forever. Must be called with t.mu held.
+func (t *Timer) stopTicker() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+	}
+	if t.done != nil {
+		close(t.done)
+		t.done = nil
+	}
+}
+
+// run counts down on every tick from ticks until done is closed. Both are
+// created together by startTicker.
+func (t *Timer) run(ticks <-chan time.Time, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticks:
+		}
+
+		t.mu.Lock()
+		select {
+		case <-done:
+			// Superseded while waiting for the lock; the tick is not ours.
+			t.mu.Unlock()
+			return
+		default:
+		}
+		t.duration -= time.Second
+		t.elapsed += time.Second
+		t.logTick(t.duration + time.Second)
+		// Notifiers may be slow (notify-send runs a process), so they are
+		// only called once the lock is released.
+		crossed := t.crossedReminders(t.duration + time.Second)
+		pending := t.reminders(crossed)
+		pending = append(pending, t.finalWarningDue(t.duration+time.Second, crossed)...)
+		if t.duration <= 0 {
+			after := t.complete(pending)
+			t.mu.Unlock()
+			after()
+			return
+		}
+		t.render()
+		t.publish(EventTick)
+		t.signalChange()
+		t.mu.Unlock()
+		t.sendNotifications(pending)
+		t.runReminderCommand(crossed)
+	}
+}
+
+// complete ends the running phase, moving on to the next one or going idle,
+// as soon as its remaining time reaches zero, whether by ticking down or by
+// a subtraction, so that no status shows a running phase with nothing left.
+// pending holds notifications already due. It returns what must be done once
+// t.mu is released: notifying, playing the sound and calling OnComplete.
+// Must be called with t.mu held.
+func (t *Timer) complete(pending []notification) func() {
+	t.stopTicker()
+	t.duration = 0
+	t.render()
+	if t.output != nil && t.inPlace {
+		fmt.Fprintln(t.output)
+	}
+	completed, elapsed := t.phase, t.elapsed
+	if completed == PhaseWork {
+		t.completedPomodoros++
+		if today := midnight(time.Now()); today.After(t.focusedSince) {
+			t.focusedSince = today
+			t.focused = 0
+		}
+		t.focused += t.elapsed
+	}
+	t.recordCompletion()
+	t.publish(EventCompleted)
+	t.flash()
+
+	next, length := t.nextPhase(completed)
+	if length <= 0 {
+		next = ""
+	}
+	switch {
+	case !t.notifications.Completion:
+	case t.elapsed < t.minNotify:
+		fmt.Fprintf(os.Stderr, "Not notifying for a %v %s, shorter than %v\n", t.elapsed, completed, t.minNotify)
+	default:
+		pending = append(pending, notification{completed, t.messages.Format(completed, next)})
+	}
+	if completed == PhaseWork {
+		pending = append(pending, t.goalReached()...)
+	}
+
+	if length > 0 {
+		t.phase = next
+		t.duration = length
+		t.elapsed = 0
+		if t.phases.BreakStartDelay > 0 {
+			t.delayBreak(t.phases.BreakStartDelay)
+		} else {
+			t.state = StateCountdown // The completed phase may have been paused
+			t.startedAt = time.Now()
+			t.startTicker()
+		}
+	} else {
+		t.state = StateIdle
+		t.phase = ""
+		t.startedAt = time.Time{}
+	}
+	t.publish(EventPhaseChanged)
+	t.signalChange()
+	return func() {
+		t.sendNotifications(pending)
+		t.writeCompletionFIFO(completed, elapsed)
+		t.playSound(completed)
+		if t.onComplete != nil {
+			t.onComplete(completed)
+		}
+	}
+}
+
+// flash starts the WithFlash hint for a completion, arranging for Changed to
+// fire once it ends. Must be called with t.mu held.
+func (t *Timer) flash() {
+	if t.flashFor <= 0 {
+		return
+	}
+	until := time.Now().Add(t.flashFor)
+	t.flashUntil = until
+	time.AfterFunc(t.flashFor, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.flashUntil.Equal(until) { // Not extended by a later completion
+			t.signalChange()
+		}
+	})
+}
+
+// AddSeconds changes the remaining time by seconds, which may be negative.
+// Adding time to an idle timer starts a work session, and taking all that is
+// left completes the phase right away. Subtractions refused by
+// WithMinRemaining are ignored; use Add to find out about them.
+func (t *Timer) AddSeconds(seconds int) {
+	t.Add(time.Duration(seconds) * time.Second)
+}
+
+// ErrBelowMinimum is returned for subtractions refused by WithMinRemaining.
+var ErrBelowMinimum = errors.New("would leave less than the minimum remaining time")
+
+// Add changes the remaining time by d like AddSeconds, returning
+// ErrBelowMinimum if it refused a subtraction.
+func (t *Timer) Add(d time.Duration) error {
+	t.mu.Lock()
+	if t.belowMinimum(d) {
+		t.mu.Unlock()
+		return ErrBelowMinimum
+	}
+	after := t.add(d)
+	t.mu.Unlock()
+	after()
+	return nil
+}
+
+// belowMinimum reports whether subtracting -d must be refused under
+// WithMinRemaining. Must be called with t.mu held.
+func (t *Timer) belowMinimum(d time.Duration) bool {
+	running := t.state == StateCountdown || t.state == StatePaused
+	return d < 0 && t.minRemaining > 0 && running && t.duration+d < t.minRemaining
+}
+
+// AddPercent adds percent of the initial duration to the remaining time.
+// percent is clamped to ±100 and the remaining time never drops below zero.
+// It returns the time actually added, or an error if there is no initial
+// duration to take a percentage of or, see WithMinRemaining, too little time
+// would be left.
+func (t *Timer) AddPercent(percent float64) (time.Duration, error) {
+	t.mu.Lock()
+	if t.initialDuration <= 0 {
+		t.mu.Unlock()
+		return 0, errors.New("no initial duration to take a percentage of")
+	}
+	percent = max(-100, min(100, percent))
+	added := time.Duration(float64(t.initialDuration) * percent / 100).Round(time.Second)
+	if t.duration+added < 0 {
+		added = -t.duration
+	}
+	if t.belowMinimum(added) {
+		t.mu.Unlock()
+		return 0, ErrBelowMinimum
+	}
+	after := t.add(added)
+	t.mu.Unlock()
+	after()
+	return added, nil
+}
+
+// add changes the remaining time by d, starting a work session if the timer
+// was idle and completing a running or paused phase left with no time. It
+// returns what complete leaves to do once t.mu is released.
+// Must be called with t.mu held.
+func (t *Timer) add(d time.Duration) func() {
+	t.duration += d
+	if (t.state == StateCountdown || t.state == StatePaused) && t.duration <= 0 {
+		return t.complete(nil)
+	}
+	if t.state == StateIdle && t.duration > 0 {
+		t.state = StateCountdown
+		t.phase = PhaseWork
+		t.elapsed = 0
+		t.startedAt = time.Now()
+		t.startTicker()
+		t.publish(EventStarted)
+	}
+	t.signalChange()
+	return func() {}
+}
+
+// ScheduleStart begins a work session after delay, or right away if delay is
+// not positive. Until then the timer is StateScheduled and scheduling again
+// replaces the pending start. It reports false if a session is already
+// counting down or paused.
+func (t *Timer) ScheduleStart(delay time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state != StateIdle && t.state != StateScheduled {
+		return false
+	}
+	t.cancelScheduledStart()
+	t.duration = t.takeWorkLength()
+	t.phase = PhaseWork
+	if delay <= 0 {
+		t.startCountdown()
+		return true
+	}
+
+	startsAt := time.Now().Add(delay)
+	t.state = StateScheduled
+	t.startsAt = startsAt
+	t.startTimer = time.AfterFunc(delay, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.state == StateScheduled && t.startsAt.Equal(startsAt) {
+			t.startTimer = nil
+			t.startsAt = time.Time{}
+			t.startCountdown()
+		}
+	})
+	t.signalChange()
+	return true
+}
+
+// delayBreak waits delay in StateStartingBreak before counting down the
+// break already set up in t.phase and t.duration. Reset cancels the wait.
+// Must be called with t.mu held.
+func (t *Timer) delayBreak(delay time.Duration) {
+	startsAt := time.Now().Add(delay)
+	t.state = StateStartingBreak
+	t.startedAt = time.Time{}
+	t.startsAt = startsAt
+	t.startTimer = time.AfterFunc(delay, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.state == StateStartingBreak && t.startsAt.Equal(startsAt) {
+			t.startTimer = nil
+			t.startsAt = time.Time{}
+			t.state = StateCountdown
+			t.startedAt = time.Now()
+			t.startTicker()
+			t.render()
+			t.signalChange()
+		}
+	})
+	t.render()
+}
+
+// startCountdown starts counting down t.duration as a work session.
+// Must be called with t.mu held.
+func (t *Timer) startCountdown() {
+	t.state = StateCountdown
+	t.phase = PhaseWork
+	t.elapsed = 0
+	t.startedAt = time.Now()
+	t.startTicker()
+	t.render()
+	t.publish(EventStarted)
+	t.signalChange()
+}
+
+// cancelScheduledStart drops a pending ScheduleStart, if any.
+// Must be called with t.mu held.
+func (t *Timer) cancelScheduledStart() {
+	if t.startTimer != nil {
+		t.startTimer.Stop()
+		t.startTimer = nil
+	}
+	t.startsAt = time.Time{}
+}
+
+// Stop stops the ticker and any pending start for good, leaving the state as
+// it is, for a server shutting down. Nothing is published or recorded.
+func (t *Timer) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopTicker()
+	if t.startTimer != nil {
+		t.startTimer.Stop()
+		t.startTimer = nil
+	}
+}
+
+// Pause stops the countdown where it is. It reports false if the timer was
+// not counting down.
+func (t *Timer) Pause() bool {
+	return t.PauseWithReason(DefaultPauseReason)
+}
+
+// DefaultPauseReason is the reason Pause gives, a pause by hand.
+const DefaultPauseReason = "manual"
+
+// PauseWithReason is Pause recording why, such as "meeting" or "screen
+// locked", which Status reports until the timer resumes. An empty reason
+// means DefaultPauseReason. Pausing a paused timer keeps its first reason.
+func (t *Timer) PauseWithReason(reason string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state != StateCountdown {
+		return false
+	}
+	if reason == "" {
+		reason = DefaultPauseReason
+	}
+	t.stopTicker()
+	t.state = StatePaused
+	t.pauseReason = reason
+	t.publish(EventPaused)
+	t.signalChange()
+	return true
+}
+
+// Resume continues a paused countdown. It reports false if the timer was not
+// paused.
+func (t *Timer) Resume() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state != StatePaused {
+		return false
+	}
+	t.state = StateCountdown
+	t.startTicker()
+	t.render()
+	t.publish(EventResumed)
+	t.signalChange()
+	return true
+}
+
+// AddMinutes is AddSeconds in minutes.
+func (t *Timer) AddMinutes(minutes int) {
+	t.AddSeconds(minutes * 60)
+}
+
+// Reset restarts a work session of the initial duration, or of a pending
+// SetNextWork override, whatever the timer was doing. The abandoned phase is
+// not counted as completed, whether it was work or a break; see
+// WithResetPreservesCount for the sessions that were.
+func (t *Timer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset(t.takeWorkLength())
+}
+
+// ResetTo is Reset with a work session of d rather than the initial
+// duration, which later sessions keep using. A SetNextWork override stays
+// pending. A d of zero or less leaves the timer idle.
+func (t *Timer) ResetTo(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset(max(d, 0))
+}
+
+// ResetInitial makes d the initial duration, as SetInitialDuration does, and
+// resets to it in one step. Zero leaves the timer idle with no initial
+// duration, so later resets stay idle too until one is set; a negative d is
+// taken as zero. A SetNextWork override stays pending.
+func (t *Timer) ResetInitial(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d = max(d, 0)
+	t.initialDuration = d
+	t.phases.Work = d
+	t.reset(d)
+}
+
+// reset implements Reset, ResetTo and ResetInitial. Must be called with t.mu held.
+func (t *Timer) reset(d time.Duration) {
+	t.cancelScheduledStart()
+	if !t.resetKeepsCount {
+		t.completedPomodoros = 0
+	}
+	t.duration = d
+	t.elapsed = 0
+	t.stopTicker()
+	if t.duration > 0 {
+		t.state = StateCountdown
+		t.phase = PhaseWork
+		t.startedAt = time.Now()
+		t.startTicker()
+		t.publish(EventStarted)
+	} else {
+		t.state = StateIdle
+		t.phase = ""
+		t.startedAt = time.Time{}
+	}
+	t.signalChange()
+}
+
+// Changed returns a channel that is closed the next time the status changes:
+// on every tick and on every state transition. Call it again after it fires
+// to wait for the following change.
+func (t *Timer) Changed() <-chan struct{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.changed
+}
+
+// signalChange wakes everyone waiting on Changed, and toggles DND and the
+// active file if work started or ended. Must be called with t.mu held.
+func (t *Timer) signalChange() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+	t.updateDND()
+	t.updateActiveFile()
+}
+
+// logTick writes to the tick log if the displayed minute changed since
+// previous. Must be called with t.mu held.
+func (t *Timer) logTick(previous time.Duration) {
+	minutes := int(t.duration.Minutes())
+	if t.tickLog == nil || minutes == int(previous.Minutes()) {
+		return
+	}
+	seconds := int(t.duration.Seconds()) % 60
+	if _, err := fmt.Fprintf(t.tickLog, "%s %s %02d:%02d\n", time.Now().Format(time.RFC3339), t.phase, minutes, seconds); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing tick log:", err)
+	}
+}
+
+// render shows the remaining time on t.output, overwriting the previous tick
+// on a terminal. Must be called with t.mu held.
+func (t *Timer) render() {
+	if t.output == nil {
+		return
+	}
+	minutes := int(t.duration.Minutes())
+	seconds := int(t.duration.Seconds()) % 60
+	line := fmt.Sprintf("%s %02d:%02d", t.phase, minutes, seconds)
+	if t.state == StateStartingBreak {
+		line = fmt.Sprintf("starting %s in %v", t.phase, time.Until(t.startsAt).Round(time.Second))
+	}
+	if t.inPlace {
+		fmt.Fprintf(t.output, "\r%-*s", t.terminalWidth-1, line)
+	} else {
+		fmt.Fprintln(t.output, line)
+	}
+}
+
+// nextPhase returns the phase that follows a completed one and its length.
+// A zero length means the cycle stops and the timer goes idle.
+// Must be called with t.mu held, after completedPomodoros was updated.
+func (t *Timer) nextPhase(completed Phase) (Phase, time.Duration) {
+	if completed != PhaseWork {
+		return "", 0
+	}
+	return t.breakAfter(t.completedPomodoros)
+}
+
+// breakAfter returns the break that follows the given number of completed
+// work sessions and its length.
+// Must be called with t.mu held.
+func (t *Timer) breakAfter(completed int) (Phase, time.Duration) {
+	interval := t.phases.LongBreakInterval
+	if interval > 0 && t.phases.LongBreak > 0 && completed%interval == 0 {
+		return PhaseLongBreak, t.phases.LongBreak
+	}
+	return PhaseShortBreak, t.phases.ShortBreak
+}
+
+// LongBreakIn estimates when the next long break starts, assuming every phase
+// runs its configured length and an idle timer is started right away.
+func (t *Timer) LongBreakIn() LongBreakEstimate {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	interval := t.phases.LongBreakInterval
+	if interval <= 0 || t.phases.LongBreak <= 0 {
+		return LongBreakEstimate{}
+	}
+
+	var sessions int
+	var in time.Duration
+	work, shortBreak := t.initialDuration, t.phases.ShortBreak
+	switch {
+	case t.state == StateIdle:
+		sessions = interval - t.completedPomodoros%interval
+		in = time.Duration(sessions)*work + time.Duration(sessions-1)*shortBreak
+	case t.phase == PhaseLongBreak:
+		sessions = 0
+	case t.phase == PhaseWork:
+		sessions = interval - t.completedPomodoros%interval
+		in = t.duration + time.Duration(sessions-1)*(shortBreak+work)
+	default: // Short break
+		sessions = interval - t.completedPomodoros%interval
+		in = t.duration + time.Duration(sessions)*work + time.Duration(sessions-1)*shortBreak
+	}
+	return LongBreakEstimate{Enabled: true, Sessions: sessions, In: in, At: time.Now().Add(in)}
+}
+
+// NextReminder returns the next reminder the running work session will
+// send, assuming a paused one is resumed right away. ok is false if none is
+// left, such as during breaks or once every reminder has fired.
+func (t *Timer) NextReminder() (estimate ReminderEstimate, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.notifications.Reminders || t.phase != PhaseWork || (t.state != StateCountdown && t.state != StatePaused) {
+		return ReminderEstimate{}, false
+	}
+	// Reminders fire as the remaining time drops to them, so the next one
+	// is the largest below the current remaining time.
+	for _, before := range t.reminderTimes {
+		if before > 0 && before < t.duration && (!ok || before > estimate.Left) {
+			estimate.Left, ok = before, true
+		}
+	}
+	if ok {
+		estimate.In = t.duration - estimate.Left
+		estimate.At = time.Now().Add(estimate.In)
+	}
+	return estimate, ok
+}
+
+// PhaseDurations returns the work/break cycle in effect. Work is the length of
+// a fresh work session, which the initial duration overrides.
+func (t *Timer) PhaseDurations() PhaseDurations {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	phases := t.phases
+	phases.Work = t.initialDuration
+	return phases
+}
+
+// SetPhaseDurations replaces the work/break cycle. The running phase keeps its
+// length unless applyNow is set, in which case its remaining time becomes
+// the new length minus the time already counted, and a phase that has
+// already run that long completes. It reports whether either changed.
+func (t *Timer) SetPhaseDurations(phases PhaseDurations, applyNow bool) bool {
+	t.mu.Lock()
+	old := t.phases
+	old.Work = t.initialDuration
+	t.phases = phases
+	t.initialDuration = phases.Work
+	if !applyNow || (t.state != StateCountdown && t.state != StatePaused) {
+		t.mu.Unlock()
+		return phases != old
+	}
+	length := phases.Work
+	switch t.phase {
+	case PhaseShortBreak:
+		length = phases.ShortBreak
+	case PhaseLongBreak:
+		length = phases.LongBreak
+	}
+	d := max(length-t.elapsed, 0) - t.duration
+	after := t.add(d) // Completes the phase if nothing is left, like AddSeconds
+	t.mu.Unlock()
+	after()
+	return phases != old || d != 0
+}
+
+// SetInitialDuration changes the length of work sessions started from now
+// on, such as by Reset. The running countdown is left alone.
+func (t *Timer) SetInitialDuration(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.initialDuration = d
+	t.phases.Work = d
+}
+
+// SetNextWork makes the next work session started, such as by Reset or
+// ScheduleStart, last d instead of the initial duration, just once. Zero
+// drops a pending override. It reports whether that changed the override.
+func (t *Timer) SetNextWork(d time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d = max(d, 0)
+	changed := t.nextWork != d
+	t.nextWork = d
+	return changed
+}
+
+// takeWorkLength returns the length of a work session starting now,
+// consuming any SetNextWork override. Must be called with t.mu held.
+func (t *Timer) takeWorkLength() time.Duration {
+	d := t.initialDuration
+	if t.nextWork > 0 {
+		d, t.nextWork = t.nextWork, 0
+	}
+	return d
+}
+
+// Status returns a snapshot of the timer. It is safe to call from any
+// goroutine.
+func (t *Timer) Status() Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	status := Status{State: t.state, Duration: t.duration, Phase: t.phase, StartedAt: t.startedAt}
+	status.Flash = time.Now().Before(t.flashUntil)
+	if t.state == StatePaused {
+		status.PauseReason = t.pauseReason
+	}
+	if t.state != StateIdle {
+		status.InitialDuration = t.elapsed + t.duration
+	}
+	if t.state == StateCountdown || t.state == StatePaused {
+		status.CycleRemaining = t.duration
+		if t.phase == PhaseWork {
+			if _, length := t.breakAfter(t.completedPomodoros + 1); length > 0 {
+				status.CycleRemaining += t.phases.BreakStartDelay + length
+			}
+		}
+	}
+	if t.state == StateScheduled || t.state == StateStartingBreak {
+		status.StartsIn = time.Until(t.startsAt)
+	}
+	if t.state == StateStartingBreak {
+		status.CycleRemaining = status.StartsIn + t.duration
+	}
+	if !midnight(time.Now()).After(t.focusedSince) {
+		status.FocusedToday = t.focused
+	}
+	return status
+}
+
+// HasHistory reports whether completed phases are recorded.
+func (t *Timer) HasHistory() bool {
+	return t.history != nil
+}
+
+// HistoryRecords returns every recorded phase, oldest first. It returns nil
+// when history is disabled.
+func (t *Timer) HistoryRecords() ([]HistoryRecord, error) {
+	if t.history == nil {
+		return nil, nil
+	}
+	return t.history.Records()
+}
+
+// HistoryRecordsSince returns the phases recorded at since or later, oldest
+// first, reading no more of the history than that needs. It returns nil
+// when history is disabled.
+func (t *Timer) HistoryRecordsSince(since time.Time) ([]HistoryRecord, error) {
+	if t.history == nil {
+		return nil, nil
+	}
+	return t.history.RecordsSince(since)
+}
+
+// ClearHistory truncates the history file and resets the completed pomodoro
+// counter. It returns the number of history records removed and whether
+// either the history or the counter changed.
+func (t *Timer) ClearHistory() (int, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counted := t.completedPomodoros > 0
+	t.completedPomodoros = 0
+	if t.history == nil {
+		return 0, counted, nil
+	}
+	removed, err := t.history.Clear()
+	return removed, counted || removed > 0, err
+}
+
+// ErrNoHistory is returned by ImportHistory when the timer keeps no history.
+var ErrNoHistory = errors.New("history is not recorded")
+
+// ImportHistory appends records, such as sessions tracked with another tool,
+// to the history, oldest first, and counts work completed today towards
+// FocusedToday. It returns how many records were written before any error.
+func (t *Timer) ImportHistory(records []HistoryRecord) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.history == nil {
+		return 0, ErrNoHistory
+	}
+	records = slices.Clone(records)
+	slices.SortStableFunc(records, func(a, b HistoryRecord) int { return a.Timestamp.Compare(b.Timestamp) })
+
+	if today := midnight(time.Now()); today.After(t.focusedSince) {
+		t.focusedSince = today
+		t.focused = 0
+	}
+	imported := 0
+	for _, record := range records {
+		if err := t.history.Append(record); err != nil {
+			return imported, err
+		}
+		imported++
+		if isWork(record.Phase) && !record.Timestamp.Before(t.focusedSince) {
+			t.focused += record.Duration
+		}
+	}
+	t.signalChange()
+	return imported, nil
+}
+
+// recordCompletion appends the just finished countdown to the history.
+// Must be called with t.mu held.
+func (t *Timer) recordCompletion() {
+	if t.history == nil {
+		return
+	}
+	record := HistoryRecord{Timestamp: time.Now(), Phase: t.phase, Duration: t.elapsed}
+	if err := t.history.Append(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing history: %v\n", err)
+	}
+}
+
+// notification is a notification waiting to be sent once t.mu is released.
+type notification struct {
+	phase   Phase
+	message string
+}
+
+// crossedReminders returns the reminder times passed since the previous
+// tick, when t.duration was previous. As they only depend on the remaining
+// time, a reset arms them all again. Must be called with t.mu held.
+func (t *Timer) crossedReminders(previous time.Duration) []time.Duration {
+	if t.phase != PhaseWork {
+		return nil
+	}
+	var crossed []time.Duration
+	for _, before := range t.reminderTimes {
+		if previous > before && t.duration <= before && t.duration > 0 {
+			crossed = append(crossed, before)
+		}
+	}
+	return crossed
+}
+
+// reminders returns the notifications for the crossed reminder times, if
+// reminders notify. Must be called with t.mu held.
+func (t *Timer) reminders(crossed []time.Duration) []notification {
+	if !t.notifications.Reminders {
+		return nil
+	}
+	var due []notification
+	for _, before := range crossed {
+		due = append(due, notification{t.phase, reminderMessage(before)})
+	}
+	return due
+}
+
+// finalWarningDue returns the WithFinalWarning notification if the
+// remaining time dropped to its lead since the previous tick, when
+// t.duration was previous, unless a reminder for the same time, among
+// crossed, already says as much. Must be called with t.mu held.
+func (t *Timer) finalWarningDue(previous time.Duration, crossed []time.Duration) []notification {
+	lead := t.finalWarning
+	if lead <= 0 || previous <= lead || t.duration > lead || t.duration <= 0 {
+		return nil
+	}
+	if t.notifications.Reminders && slices.Contains(crossed, lead) {
+		return nil
+	}
+	return []notification{{t.phase, reminderMessage(lead)}}
+}
+
+// runReminderCommand starts the WithReminderCommand command for each crossed
+// reminder time in the background.
+func (t *Timer) runReminderCommand(crossed []time.Duration) {
+	if t.reminderCommand == "" {
+		return
+	}
+	for _, before := range crossed {
+		go func(seconds string) {
+			if err := runShell(t.reminderCommand, seconds); err != nil {
+				fmt.Fprintf(os.Stderr, "Error running reminder command %q: %v\n", t.reminderCommand, err)
+			}
+		}(strconv.Itoa(int(before.Seconds())))
+	}
+}
+
+// reminderMessage describes a reminder sent with left remaining.
+func reminderMessage(left time.Duration) string {
+	if left%time.Minute == 0 {
+		return fmt.Sprintf("%d min left", int(left.Minutes()))
+	}
+	return fmt.Sprintf("%s left", left)
+}
+
+// sendNotifications hands each notification to the configured Notifier, if
+// any and outside quiet hours. It must be called without t.mu held, so that
+// a slow Notifier does not stall status queries.
+func (t *Timer) sendNotifications(pending []notification) {
+	if t.notify == nil || t.quiet(time.Now()) {
+		return
+	}
+	for _, n := range pending {
+		t.notify(n.phase, "Pomidoras", n.message)
+	}
+}
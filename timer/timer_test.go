@@ -0,0 +1,585 @@
+package timer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResetDoesNotLeakGoroutines(t *testing.T) {
+	tm := New(10 * time.Minute)
+	tm.Start()
+	defer tm.Pause()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		tm.Reset()
+	}
+	// Superseded run goroutines exit asynchronously.
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after 100 resets, want at most %d", after, before)
+	}
+}
+
+func TestResetCount(t *testing.T) {
+	phases := PhaseDurations{Work: 25 * time.Minute, ShortBreak: 5 * time.Minute, LongBreak: 15 * time.Minute, LongBreakInterval: 4}
+	tests := []struct {
+		name      string
+		phase     Phase
+		completed int // Work sessions completed before the reset
+		preserve  bool
+		sessions  int // Work sessions before the next long break afterwards
+	}{
+		{"work kept", PhaseWork, 2, true, 2},
+		{"short break kept", PhaseShortBreak, 3, true, 1},
+		{"long break kept", PhaseLongBreak, 4, true, 4},
+		{"work cleared", PhaseWork, 2, false, 4},
+		{"short break cleared", PhaseShortBreak, 3, false, 4},
+		{"long break cleared", PhaseLongBreak, 4, false, 4},
+	}
+	for _, tt := range tests {
+		tm := New(25*time.Minute, WithPhases(phases), WithResetPreservesCount(tt.preserve))
+		tm.mu.Lock()
+		tm.phase = tt.phase
+		tm.completedPomodoros = tt.completed
+		tm.mu.Unlock()
+
+		tm.Reset()
+		tm.Pause()
+		if status := tm.Status(); status.Phase != PhaseWork || status.Duration != 25*time.Minute {
+			t.Errorf("%s: got %s with %v left, want a fresh work session", tt.name, status.Phase, status.Duration)
+		}
+		if estimate := tm.LongBreakIn(); estimate.Sessions != tt.sessions {
+			t.Errorf("%s: got a long break after %d sessions, want %d", tt.name, estimate.Sessions, tt.sessions)
+		}
+	}
+}
+
+func TestCycleRemaining(t *testing.T) {
+	phases := PhaseDurations{Work: 25 * time.Minute, ShortBreak: 5 * time.Minute, LongBreak: 15 * time.Minute, LongBreakInterval: 4}
+	tests := []struct {
+		name      string
+		phase     Phase
+		completed int
+		want      time.Duration
+	}{
+		{"work before a short break", PhaseWork, 0, 30 * time.Minute},
+		{"work before a long break", PhaseWork, 3, 40 * time.Minute},
+		{"short break", PhaseShortBreak, 1, 25 * time.Minute},
+		{"long break", PhaseLongBreak, 4, 25 * time.Minute},
+	}
+	for _, tt := range tests {
+		tm := New(25*time.Minute, WithPhases(phases))
+		tm.Pause()
+		tm.mu.Lock()
+		tm.phase = tt.phase
+		tm.completedPomodoros = tt.completed
+		tm.mu.Unlock()
+
+		if got := tm.Status().CycleRemaining; got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := New(0).Status().CycleRemaining; got != 0 {
+		t.Errorf("idle: got %v, want 0", got)
+	}
+}
+
+func TestNextReminder(t *testing.T) {
+	tm := New(30*time.Minute, WithReminders(10*time.Minute, 5*time.Minute, 40*time.Minute))
+
+	estimate, ok := tm.NextReminder()
+	if !ok || estimate.Left != 10*time.Minute || estimate.In != 20*time.Minute {
+		t.Errorf("got %+v (ok %t), want 10m left in 20m", estimate, ok)
+	}
+	tm.AddSeconds(-23 * 60) // 7m left, past the 10m reminder
+	if estimate, ok := tm.NextReminder(); !ok || estimate.Left != 5*time.Minute || estimate.In != 2*time.Minute {
+		t.Errorf("got %+v (ok %t), want 5m left in 2m", estimate, ok)
+	}
+	tm.AddSeconds(-3 * 60)
+	if estimate, ok := tm.NextReminder(); ok {
+		t.Errorf("got %+v, want none once every reminder has passed", estimate)
+	}
+
+	off := New(30*time.Minute, WithReminders(10*time.Minute), WithNotifications(Notifications{Completion: true}))
+	if estimate, ok := off.NextReminder(); ok {
+		t.Errorf("got %+v with reminders off, want none", estimate)
+	}
+}
+
+func TestMinRemaining(t *testing.T) {
+	tm := New(10*time.Minute, WithMinRemaining(2*time.Minute))
+
+	if err := tm.Add(-8 * time.Minute); err != nil {
+		t.Errorf("subtracting down to the floor: %v", err)
+	}
+	if err := tm.Add(-time.Second); err != ErrBelowMinimum {
+		t.Errorf("subtracting below the floor: got %v, want ErrBelowMinimum", err)
+	}
+	tm.AddSeconds(-60)
+	if _, err := tm.AddPercent(-10); err != ErrBelowMinimum {
+		t.Errorf("percentage below the floor: got %v, want ErrBelowMinimum", err)
+	}
+	if status := tm.Status(); status.Duration != 2*time.Minute {
+		t.Errorf("got %v remaining, want refused subtractions to leave 2m", status.Duration)
+	}
+	if err := tm.Add(time.Minute); err != nil {
+		t.Errorf("adding time: %v", err)
+	}
+
+	// An idle timer has no session to protect.
+	if err := New(0, WithMinRemaining(time.Minute)).Add(-time.Second); err != nil {
+		t.Errorf("idle: got %v, want no guard", err)
+	}
+}
+
+func TestNotificationToggles(t *testing.T) {
+	tests := []struct {
+		notifications Notifications
+		want          []string
+	}{
+		{Notifications{Reminders: true, Completion: true}, []string{"1s left", "Work complete — take a break"}},
+		{Notifications{Reminders: true}, []string{"1s left"}},
+		{Notifications{Completion: true}, []string{"Work complete — take a break"}},
+	}
+	for _, tt := range tests {
+		sent := make(chan string, 10)
+		tm := New(2*time.Second,
+			WithPhases(PhaseDurations{Work: 2 * time.Second}),
+			WithReminders(time.Second),
+			WithNotifications(tt.notifications),
+			WithNotifier(func(phase Phase, title, message string) {
+				sent <- message
+			}))
+		tm.Start()
+
+		// Notifications are sent after the lock is released, so they may
+		// arrive just after the timer goes idle.
+		var got []string
+		timeout := time.After(5 * time.Second)
+		for len(got) < len(tt.want) {
+			select {
+			case message := <-sent:
+				got = append(got, message)
+			case <-timeout:
+				t.Fatalf("%+v: got %q before timing out, want %q", tt.notifications, got, tt.want)
+			}
+		}
+		for tm.Status().State != StateIdle {
+			time.Sleep(50 * time.Millisecond)
+		}
+		select {
+		case message := <-sent:
+			got = append(got, message)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%+v: got %q, want %q", tt.notifications, got, tt.want)
+		}
+	}
+}
+
+func TestReminderMessage(t *testing.T) {
+	if got := reminderMessage(10 * time.Minute); got != "10 min left" {
+		t.Errorf("got %q, want 10 min left", got)
+	}
+	if got := reminderMessage(90 * time.Second); got != "1m30s left" {
+		t.Errorf("got %q, want 1m30s left", got)
+	}
+}
+
+func TestStatusDuringSlowNotification(t *testing.T) {
+	notifying := make(chan struct{})
+	notified := make(chan struct{})
+	tm := New(time.Second,
+		WithPhases(PhaseDurations{Work: time.Second}),
+		WithNotifier(func(phase Phase, title, message string) {
+			close(notifying)
+			time.Sleep(300 * time.Millisecond) // Like a slow notify-send
+			close(notified)
+		}))
+	tm.Start()
+	<-notifying
+
+	const readers = 50
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		all []time.Duration
+	)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var latencies []time.Duration
+			for {
+				select {
+				case <-notified:
+					mu.Lock()
+					all = append(all, latencies...)
+					mu.Unlock()
+					return
+				default:
+				}
+				start := time.Now()
+				tm.Status()
+				latencies = append(latencies, time.Since(start))
+			}
+		}()
+	}
+	wg.Wait()
+	if len(all) == 0 {
+		t.Fatal("no status queries completed during the notification")
+	}
+	slices.Sort(all)
+	if p99 := all[len(all)*99/100]; p99 > 5*time.Millisecond {
+		t.Errorf("p99 Status latency %v over %d queries, want under 5ms", p99, len(all))
+	}
+}
+
+func TestBreakStartDelay(t *testing.T) {
+	phases := PhaseDurations{Work: time.Second, ShortBreak: 5 * time.Minute, BreakStartDelay: 2 * time.Second}
+	tm := New(time.Second, WithPhases(phases))
+	tm.Start()
+	defer tm.Reset()
+
+	waitFor := func(state State) Status {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			changed := tm.Changed()
+			if status := tm.Status(); status.State == state {
+				return status
+			}
+			select {
+			case <-changed:
+			case <-deadline:
+				t.Fatalf("timer did not reach %s, got %+v", state, tm.Status())
+			}
+		}
+	}
+
+	status := waitFor(StateStartingBreak)
+	if status.Phase != PhaseShortBreak || status.Duration != 5*time.Minute || status.StartsIn <= 0 || !status.StartedAt.IsZero() {
+		t.Errorf("got %+v, want the short break starting later", status)
+	}
+	if tm.Pause() {
+		t.Error("paused while waiting for the break")
+	}
+
+	status = waitFor(StateCountdown)
+	if status.Phase != PhaseShortBreak || status.StartedAt.IsZero() {
+		t.Errorf("got %+v, want the short break counting down", status)
+	}
+}
+
+func TestMinNotifyDuration(t *testing.T) {
+	for _, tt := range []struct {
+		min  time.Duration
+		want int
+	}{{5 * time.Second, 0}, {time.Second, 1}} {
+		var sent int
+		done := make(chan struct{})
+		tm := New(time.Second,
+			WithPhases(PhaseDurations{Work: time.Second}),
+			WithMinNotifyDuration(tt.min),
+			WithNotifier(func(Phase, string, string) { sent++ }),
+			OnComplete(func(Phase) { close(done) }))
+		tm.Start()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timer did not complete")
+		}
+		if sent != tt.want {
+			t.Errorf("minimum %v: sent %d notifications, want %d", tt.min, sent, tt.want)
+		}
+	}
+}
+
+func TestFinalSecond(t *testing.T) {
+	done := make(chan struct{})
+	tm := New(time.Second,
+		WithPhases(PhaseDurations{Work: time.Second}),
+		OnComplete(func(Phase) { close(done) }))
+	tm.Start()
+
+	// Status must never catch the phase still running with nothing left.
+	deadline := time.After(5 * time.Second)
+	for polling := true; polling; {
+		select {
+		case <-done:
+			polling = false
+		case <-deadline:
+			t.Fatal("timer did not complete")
+		default:
+		}
+		if status := tm.Status(); status.State == StateCountdown && status.Duration <= 0 {
+			t.Fatalf("got %+v, want idle once nothing is left", status)
+		}
+	}
+	if status := tm.Status(); status.State != StateIdle || status.Duration != 0 {
+		t.Errorf("after the last second got %+v, want idle", status)
+	}
+}
+
+func TestStop(t *testing.T) {
+	tm := New(time.Minute)
+	tm.Start()
+	tm.Stop()
+	if tm.done != nil {
+		t.Error("the run goroutine was not stopped")
+	}
+	if status := tm.Status(); status.State != StateCountdown {
+		t.Errorf("got state %v, want it left counting down", status.State)
+	}
+
+	tm = New(0)
+	tm.ScheduleStart(10 * time.Millisecond)
+	tm.Stop()
+	time.Sleep(50 * time.Millisecond)
+	if status := tm.Status(); status.State != StateScheduled {
+		t.Errorf("got state %v, want the start dropped", status.State)
+	}
+}
+
+func TestApplyNowCompletes(t *testing.T) {
+	tm := New(10*time.Minute, WithPhases(PhaseDurations{Work: 10 * time.Minute, ShortBreak: 5 * time.Minute}))
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	go tm.run(ticks, done)
+	ticks <- time.Now()
+	ticks <- time.Now() // Two seconds counted once handled
+	close(done)
+	defer tm.Pause()
+
+	// The work session has already run longer than its new length.
+	if !tm.SetPhaseDurations(PhaseDurations{Work: time.Second, ShortBreak: 5 * time.Minute}, true) {
+		t.Error("got no change")
+	}
+	if status := tm.Status(); status.State != StateCountdown || status.Phase != PhaseShortBreak || status.Duration != 5*time.Minute {
+		t.Errorf("got %+v, want the short break counting down", status)
+	}
+}
+
+func TestActiveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pomidoras.active")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tm := New(10*time.Minute, WithPhases(PhaseDurations{Work: 10 * time.Minute, ShortBreak: 5 * time.Minute}), WithActiveFile(path))
+	defer tm.Pause()
+	exists := func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	if exists() {
+		t.Error("stale file kept before the session started")
+	}
+
+	tm.Start()
+	if !exists() {
+		t.Error("no file while work counts down")
+	}
+	tm.Pause()
+	if exists() {
+		t.Error("file kept while paused")
+	}
+	tm.Resume()
+	tm.AddSeconds(-10 * 60) // On to the break
+	if exists() || tm.Status().Phase != PhaseShortBreak {
+		t.Error("file kept during the break")
+	}
+	tm.Reset()
+	if !exists() {
+		t.Error("no file after a reset started work again")
+	}
+}
+
+func TestFlash(t *testing.T) {
+	tm := New(10*time.Minute,
+		WithPhases(PhaseDurations{Work: 10 * time.Minute}),
+		WithFlash(200*time.Millisecond))
+	if tm.Status().Flash {
+		t.Fatal("flashing before anything completed")
+	}
+	tm.AddSeconds(-10 * 60)
+	status := tm.Status()
+	if !status.Flash || status.State != StateIdle {
+		t.Fatalf("got %+v, want idle and flashing after completing", status)
+	}
+
+	select {
+	case <-tm.Changed():
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change when the flash ended")
+	}
+	if tm.Status().Flash {
+		t.Error("still flashing after the flash ended")
+	}
+}
+
+func TestSubtractToZero(t *testing.T) {
+	for _, paused := range []bool{false, true} {
+		var completed []Phase
+		tm := New(10*time.Minute,
+			WithPhases(PhaseDurations{Work: 10 * time.Minute}),
+			OnComplete(func(phase Phase) { completed = append(completed, phase) }))
+		tm.Start()
+		if paused {
+			tm.Pause()
+		}
+		tm.AddSeconds(-15 * 60) // More than is left
+		if status := tm.Status(); status.State != StateIdle || status.Duration != 0 {
+			t.Errorf("paused %t: got %+v, want idle right away", paused, status)
+		}
+		if !slices.Equal(completed, []Phase{PhaseWork}) {
+			t.Errorf("paused %t: completed %v, want the work session", paused, completed)
+		}
+	}
+}
+
+func TestSetNextWork(t *testing.T) {
+	tm := New(0, WithPhases(PhaseDurations{Work: 25 * time.Minute}))
+	defer tm.Pause()
+
+	tm.SetNextWork(50 * time.Minute)
+	if !tm.ScheduleStart(0) {
+		t.Fatal("ScheduleStart refused an idle timer")
+	}
+	if got := tm.Status().Duration; got != 50*time.Minute {
+		t.Errorf("next work session got %v, want 50m", got)
+	}
+	tm.Reset()
+	if got := tm.Status().Duration; got != 25*time.Minute {
+		t.Errorf("following work session got %v, want the usual 25m", got)
+	}
+
+	tm.SetNextWork(50 * time.Minute)
+	tm.SetNextWork(0)
+	tm.Reset()
+	if got := tm.Status().Duration; got != 25*time.Minute {
+		t.Errorf("after dropping the override got %v, want 25m", got)
+	}
+}
+
+func TestTickLog(t *testing.T) {
+	var log strings.Builder
+	tm := New(61*time.Second, WithTickLog(&log))
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	go tm.run(ticks, done)
+	// Receiving a tick means the previous one has been handled.
+	for range 3 {
+		ticks <- time.Now()
+	}
+	close(done)
+
+	lines := strings.Split(strings.TrimSuffix(log.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("logged %q, want one line", log.String())
+	}
+	stamp, rest, _ := strings.Cut(lines[0], " ")
+	if _, err := time.Parse(time.RFC3339, stamp); err != nil {
+		t.Errorf("timestamp: %v", err)
+	}
+	if rest != "work 00:59" {
+		t.Errorf("logged %q, want %q", rest, "work 00:59")
+	}
+}
+
+func TestPauseWithReason(t *testing.T) {
+	tm := New(time.Minute)
+	if !tm.PauseWithReason("meeting") || tm.PauseWithReason("lunch") {
+		t.Fatal("want only the first pause to take effect")
+	}
+	if got := tm.Status().PauseReason; got != "meeting" {
+		t.Errorf("got reason %q, want the first one", got)
+	}
+	tm.Resume()
+	defer tm.Pause()
+	if got := tm.Status().PauseReason; got != "" {
+		t.Errorf("got reason %q once resumed, want none", got)
+	}
+}
+
+func TestFinalWarning(t *testing.T) {
+	sent := make(chan string, 10)
+	tm := New(3*time.Second,
+		WithFinalWarning(2*time.Second),
+		WithNotifications(Notifications{}), // Independent of reminders
+		WithNotifier(func(phase Phase, title, message string) {
+			sent <- message
+		}))
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	go tm.run(ticks, done)
+	defer close(done)
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case message := <-sent:
+			if message != want {
+				t.Errorf("got %q, want %q", message, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %q warning", want)
+		}
+	}
+	ticks <- time.Now() // 2s left
+	expect("2s left")
+	ticks <- time.Now() // 1s left, already warned
+	// Adding time past the lead arms the warning again.
+	if err := tm.Add(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	ticks <- time.Now() // 2s left again
+	expect("2s left")
+	select {
+	case message := <-sent:
+		t.Errorf("got %q, want a single warning each time", message)
+	default:
+	}
+}
+
+func TestReminderCommand(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "reminders")
+	tm := New(3*time.Second,
+		WithPhases(PhaseDurations{Work: 3 * time.Second}),
+		WithReminders(2*time.Second),
+		WithNotifications(Notifications{}), // The command runs without notifications
+		WithReminderCommand("echo >> "+log))
+	tm.Start()
+	defer tm.Pause()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			got, _ := os.ReadFile(log)
+			if string(got) == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("2\n")
+
+	// A reset arms the reminder again.
+	tm.Reset()
+	waitFor("2\n2\n")
+}